@@ -0,0 +1,260 @@
+package cookies
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Jar 可查询、清除及持久化的cookie容器，
+	// cookie的匹配由net/http/cookiejar完成，另记录完整的cookie用于查询及保存
+	Jar struct {
+		jar     *cookiejar.Jar
+		entries map[string]*Entry // [domain;path;name]
+		sync.RWMutex
+	}
+	// Entry 记录的单个cookie
+	Entry struct {
+		Url      string       // 设置该cookie的地址
+		HostOnly bool         // 是否仅用于设置该cookie的主机（未指定Domain属性）
+		Cookie   *http.Cookie // Domain及Path已补全，MaxAge已换算为Expires
+	}
+)
+
+// New 创建空的cookie容器
+func New() *Jar {
+	jar, _ := cookiejar.New(nil)
+	return &Jar{
+		jar:     jar,
+		entries: make(map[string]*Entry),
+	}
+}
+
+// SetCookies 实现http.CookieJar接口
+func (self *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	self.Lock()
+	defer self.Unlock()
+	self.jar.SetCookies(u, cookies)
+	self.record(u, cookies, time.Now())
+}
+
+// Cookies 实现http.CookieJar接口
+func (self *Jar) Cookies(u *url.URL) []*http.Cookie {
+	self.RLock()
+	defer self.RUnlock()
+	return self.jar.Cookies(u)
+}
+
+// Get 返回属于domain及其子域名的未过期cookie，domain为空时返回全部
+func (self *Jar) Get(domain string) []*http.Cookie {
+	self.RLock()
+	defer self.RUnlock()
+	domain = hostOf(domain)
+	now := time.Now()
+	var cookies []*http.Cookie
+	for _, e := range self.entries {
+		if expired(e.Cookie, now) || !domainMatch(e.Cookie.Domain, domain) {
+			continue
+		}
+		c := *e.Cookie
+		cookies = append(cookies, &c)
+	}
+	sort.Slice(cookies, func(i, j int) bool {
+		a, b := cookies[i], cookies[j]
+		if a.Domain != b.Domain {
+			return a.Domain < b.Domain
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Name < b.Name
+	})
+	return cookies
+}
+
+// Set 为domain设置cookie，domain可为域名或url，cookie未指定Path时为"/"
+func (self *Jar) Set(domain string, cookies ...*http.Cookie) {
+	u := urlOf(domain)
+	if u == nil {
+		return
+	}
+	for _, c := range cookies {
+		if c.Path == "" {
+			c.Path = "/"
+		}
+	}
+	self.SetCookies(u, cookies)
+}
+
+// Clear 清除属于domain及其子域名的cookie，domain为空时清除全部，返回清除的数量
+func (self *Jar) Clear(domain string) int {
+	self.Lock()
+	defer self.Unlock()
+	domain = hostOf(domain)
+	var n int
+	for key, e := range self.entries {
+		if domainMatch(e.Cookie.Domain, domain) {
+			delete(self.entries, key)
+			n++
+		}
+	}
+	if n > 0 {
+		// cookiejar不支持删除，以剩余的cookie重建
+		self.rebuild(time.Now())
+	}
+	return n
+}
+
+// Load 从文件恢复cookie，已过期的cookie被忽略
+func (self *Jar) Load(fileName string) error {
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	var entries []*Entry
+	if err = json.Unmarshal(b, &entries); err != nil {
+		return err
+	}
+	self.Lock()
+	defer self.Unlock()
+	for _, e := range entries {
+		if e.Cookie != nil {
+			self.entries[key(e.Cookie)] = e
+		}
+	}
+	self.rebuild(time.Now())
+	return nil
+}
+
+// Save 将未过期的cookie保存至文件
+func (self *Jar) Save(fileName string) error {
+	self.RLock()
+	now := time.Now()
+	entries := make([]*Entry, 0, len(self.entries))
+	for _, e := range self.entries {
+		if !expired(e.Cookie, now) {
+			entries = append(entries, e)
+		}
+	}
+	self.RUnlock()
+	sort.Slice(entries, func(i, j int) bool { return key(entries[i].Cookie) < key(entries[j].Cookie) })
+
+	b, err := json.MarshalIndent(entries, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(fileName), 0777); err != nil {
+		return err
+	}
+	tmp := fileName + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fileName)
+}
+
+// 记录设置的cookie，MaxAge<0或已过期时删除记录
+func (self *Jar) record(u *url.URL, cookies []*http.Cookie, now time.Time) {
+	for _, c := range cookies {
+		c := *c
+		hostOnly := c.Domain == ""
+		if hostOnly {
+			c.Domain = u.Hostname()
+		}
+		c.Domain = strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+		if c.Path == "" || c.Path[0] != '/' {
+			c.Path = defaultPath(u.Path)
+		}
+		switch {
+		case c.MaxAge < 0:
+			c.Expires = now
+		case c.MaxAge > 0:
+			c.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		}
+		c.MaxAge, c.Raw, c.RawExpires = 0, "", ""
+
+		k := key(&c)
+		if expired(&c, now) {
+			delete(self.entries, k)
+			continue
+		}
+		self.entries[k] = &Entry{
+			Url:      (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String(),
+			HostOnly: hostOnly,
+			Cookie:   &c,
+		}
+	}
+}
+
+// 以记录的cookie重建cookiejar，同时移除已过期的记录
+func (self *Jar) rebuild(now time.Time) {
+	self.jar, _ = cookiejar.New(nil)
+	for k, e := range self.entries {
+		u, err := url.Parse(e.Url)
+		if err != nil || expired(e.Cookie, now) {
+			delete(self.entries, k)
+			continue
+		}
+		c := *e.Cookie
+		if e.HostOnly {
+			c.Domain = ""
+		}
+		self.jar.SetCookies(u, []*http.Cookie{&c})
+	}
+}
+
+func key(c *http.Cookie) string {
+	return c.Domain + ";" + c.Path + ";" + c.Name
+}
+
+func expired(c *http.Cookie, now time.Time) bool {
+	return !c.Expires.IsZero() && !c.Expires.After(now)
+}
+
+// cookie的域名是否为domain或其子域名
+func domainMatch(cookieDomain, domain string) bool {
+	return domain == "" || cookieDomain == domain || strings.HasSuffix(cookieDomain, "."+domain)
+}
+
+// 由url的路径得出cookie的默认Path
+func defaultPath(p string) string {
+	if p == "" || p[0] != '/' {
+		return "/"
+	}
+	if i := strings.LastIndex(p, "/"); i > 0 {
+		return p[:i]
+	}
+	return "/"
+}
+
+// 将域名或url转为url，域名视为http协议的根路径
+func urlOf(domain string) *url.URL {
+	if !strings.Contains(domain, "://") {
+		domain = "http://" + domain + "/"
+	}
+	u, err := url.Parse(domain)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	return u
+}
+
+// 返回域名或url中的主机名
+func hostOf(domain string) string {
+	if domain == "" {
+		return ""
+	}
+	if u := urlOf(domain); u != nil {
+		domain = u.Hostname()
+	}
+	return strings.ToLower(strings.TrimPrefix(domain, "."))
+}
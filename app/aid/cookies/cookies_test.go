@@ -0,0 +1,61 @@
+package cookies
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestJar(t *testing.T) {
+	u, _ := url.Parse("https://www.example.com/account/login")
+	jar := New()
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "sid", Value: "1"},
+		{Name: "uid", Value: "2", Domain: ".example.com", Path: "/", MaxAge: 3600},
+		{Name: "gone", Value: "3", MaxAge: -1},
+	})
+	jar.Set("other.com", &http.Cookie{Name: "token", Value: "4"})
+
+	// 实例间不共享
+	if len(New().Get("")) != 0 {
+		t.Fatal("new jar not empty")
+	}
+	got := jar.Get("example.com")
+	if len(got) != 2 || got[0].Name != "uid" || got[1].Name != "sid" || got[1].Path != "/account" || got[0].Expires.IsZero() {
+		t.Fatalf("get = %v", got)
+	}
+	if cs := jar.Cookies(&url.URL{Scheme: "http", Host: "other.com", Path: "/x"}); len(cs) != 1 || cs[0].Value != "4" {
+		t.Fatalf("cookies = %v", cs)
+	}
+
+	// 保存后恢复，仅主机有效的cookie不扩展至子域名
+	fileName := filepath.Join(t.TempDir(), "jar.json")
+	if err := jar.Save(fileName); err != nil {
+		t.Fatal(err)
+	}
+	loaded := New()
+	if err := loaded.Load(fileName); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Get("")) != 3 {
+		t.Fatalf("loaded = %v", loaded.Get(""))
+	}
+	if cs := loaded.Cookies(&url.URL{Scheme: "https", Host: "www.example.com", Path: "/account/info"}); len(cs) != 2 {
+		t.Fatalf("www cookies = %v", cs)
+	}
+	if cs := loaded.Cookies(&url.URL{Scheme: "https", Host: "m.www.example.com", Path: "/account/"}); len(cs) != 1 || cs[0].Name != "uid" {
+		t.Fatalf("subdomain cookies = %v", cs)
+	}
+
+	// 按域名清除
+	if n := loaded.Clear("https://example.com/"); n != 2 {
+		t.Fatalf("cleared %d", n)
+	}
+	if cs := loaded.Cookies(&url.URL{Scheme: "https", Host: "www.example.com", Path: "/account/"}); len(cs) != 0 {
+		t.Fatalf("after clear = %v", cs)
+	}
+	if len(loaded.Get("other.com")) != 1 {
+		t.Fatal("other domain cleared")
+	}
+}
@@ -0,0 +1,138 @@
+package frontier
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/molast/crawler-core/app/downloader/request"
+	"github.com/molast/crawler-core/common/buckets"
+	"github.com/molast/crawler-core/common/util"
+	"github.com/molast/crawler-core/config"
+	"github.com/molast/crawler-core/logs"
+)
+
+// Frontier 持久化的待抓取请求队列（断点续爬），
+// 每个Spider实例(name+subName)对应一个boltDB文件，
+// 每次加入队列时为请求分配新的键（Request.FrontierKey），保存尚未完成的请求，
+// 因此可重复下载的相同请求各自保存，互不覆盖。
+type Frontier struct {
+	fileName string
+	db       *buckets.DB
+	bucket   *buckets.Bucket
+	closed   bool
+	seq      uint32 // 键的序号，原子操作
+	sync.RWMutex
+}
+
+var bucketName = []byte(config.FRONTIER_TAG)
+
+// New 创建/打开指定Spider实例的请求队列文件
+func New(name string, subName string) (*Frontier, error) {
+	baseName := config.FRONTIER_TAG + "__" + name
+	if subName != "" {
+		baseName += "__" + subName
+	}
+	fileName := config.FRONTIER_DIR + "/" + util.FileNameReplace(baseName) + ".db"
+
+	db, err := buckets.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	bucket, err := db.New(bucketName)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Frontier{
+		fileName: fileName,
+		db:       db,
+		bucket:   bucket,
+	}, nil
+}
+
+// Put 保存一条待抓取请求，请求尚无键时分配新的键，
+// 已有键时（如从断点恢复的请求）覆盖原记录
+func (self *Frontier) Put(req *request.Request) error {
+	self.RLock()
+	defer self.RUnlock()
+	if self.closed {
+		return nil
+	}
+	if req.FrontierKey == "" {
+		req.FrontierKey = self.nextKey()
+	}
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	return self.bucket.Put([]byte(req.FrontierKey), b)
+}
+
+// Delete 删除一条已完成（成功或最终失败）的请求
+func (self *Frontier) Delete(req *request.Request) error {
+	self.RLock()
+	defer self.RUnlock()
+	if self.closed || req.FrontierKey == "" {
+		return nil
+	}
+	return self.bucket.Delete([]byte(req.FrontierKey))
+}
+
+// 生成新的键，按加入队列的先后排序，读取时即按原顺序恢复
+func (self *Frontier) nextKey() string {
+	return fmt.Sprintf("%016x%08x", time.Now().UnixNano(), atomic.AddUint32(&self.seq, 1))
+}
+
+// Load 读取上次未完成的全部请求
+func (self *Frontier) Load() ([]*request.Request, error) {
+	self.RLock()
+	defer self.RUnlock()
+	if self.closed {
+		return nil, nil
+	}
+	items, err := self.bucket.Items()
+	if err != nil {
+		return nil, err
+	}
+	reqs := make([]*request.Request, 0, len(items))
+	for _, item := range items {
+		req, err := request.UnSerialize(string(item.Value))
+		if err != nil {
+			logs.Log.Error(" *     Fail  [读取请求队列][%s]: %v\n", item.Key, err)
+			continue
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, nil
+}
+
+// Len 返回已保存的请求数
+func (self *Frontier) Len() int {
+	self.RLock()
+	defer self.RUnlock()
+	if self.closed {
+		return 0
+	}
+	n, _ := self.bucket.Count()
+	return n
+}
+
+// Close 关闭请求队列文件，
+// clear为true时（任务正常结束）同时删除该文件。
+func (self *Frontier) Close(clear bool) error {
+	self.Lock()
+	defer self.Unlock()
+	if self.closed {
+		return nil
+	}
+	self.closed = true
+	err := self.db.Close()
+	if clear {
+		os.Remove(self.fileName)
+	}
+	return err
+}
@@ -0,0 +1,70 @@
+package frontier
+
+import (
+	"os"
+	"testing"
+
+	"github.com/molast/crawler-core/app/downloader/request"
+)
+
+func TestFrontier(t *testing.T) {
+	f, err := New("frontier_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.fileName)
+
+	// 可重复下载的相同请求各自保存
+	reqs := []*request.Request{
+		{Spider: "frontier_test", Url: "http://a.com/poll", Rule: "poll", Reloadable: true},
+		{Spider: "frontier_test", Url: "http://a.com/poll", Rule: "poll", Reloadable: true},
+		{Spider: "frontier_test", Url: "http://a.com/list", Rule: "list"},
+	}
+	for _, req := range reqs {
+		if err := f.Put(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if f.Len() != 3 || reqs[0].FrontierKey == reqs[1].FrontierKey {
+		t.Fatalf("len = %d, keys = %q %q", f.Len(), reqs[0].FrontierKey, reqs[1].FrontierKey)
+	}
+	// 完成其中一个不影响另一个
+	if err := f.Delete(reqs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(false); err != nil {
+		t.Fatal(err)
+	}
+
+	// 中断后恢复，按加入队列的顺序读取
+	f, err = New("frontier_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := f.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 2 || loaded[0].FrontierKey != reqs[1].FrontierKey || loaded[1].GetUrl() != "http://a.com/list" {
+		t.Fatalf("loaded = %v", loaded)
+	}
+	// 恢复的请求重新加入队列时沿用原有的键
+	for _, req := range loaded {
+		f.Put(req)
+	}
+	if f.Len() != 2 {
+		t.Fatalf("len = %d", f.Len())
+	}
+	for _, req := range loaded {
+		f.Delete(req)
+	}
+	if f.Len() != 0 {
+		t.Fatalf("len = %d", f.Len())
+	}
+
+	fileName := f.fileName
+	f.Close(true)
+	if _, err := os.Stat(fileName); !os.IsNotExist(err) {
+		t.Fatalf("file not removed: %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package history
+
+import (
+	"bufio"
+	"os"
+
+	"github.com/molast/crawler-core/common/goutil/bloom"
+	"github.com/molast/crawler-core/runtime/cache"
+)
+
+// 布隆过滤器的初始容量
+const bloomCapacity = 1 << 20
+
+// Deduper 成功记录的去重存储，非并发安全，由调用方加锁
+type Deduper interface {
+	Has(key string) bool        // 检查是否存在（布隆过滤器模式下可能误判为存在）
+	Add(key string) bool        // 加入记录，返回是否为新增
+	Len() int                   // 已记录数量
+	Save(fileName string) error // 写入文件，仅布隆过滤器模式下有效
+	Load(fileName string) bool  // 从文件读取，仅布隆过滤器模式下有效
+}
+
+// dedup 默认的去重存储，
+// 记录数较少时使用精确的map，超过阈值后自动转为可扩容的布隆过滤器。
+type dedup struct {
+	exact  map[string]bool
+	bloom  *bloom.Scalable
+	after  int     // 转为布隆过滤器的记录数阈值，<=0时始终使用map
+	fpRate float64 // 布隆过滤器的误判率
+}
+
+// NewDeduper 根据全局配置创建去重存储
+func NewDeduper() Deduper {
+	return &dedup{
+		exact:  make(map[string]bool),
+		after:  cache.Task.BloomAfter,
+		fpRate: cache.Task.BloomRate,
+	}
+}
+
+func (self *dedup) Has(key string) bool {
+	if self.bloom != nil {
+		return self.bloom.Has([]byte(key))
+	}
+	return self.exact[key]
+}
+
+func (self *dedup) Add(key string) bool {
+	if self.bloom != nil {
+		return self.bloom.Add([]byte(key))
+	}
+	if self.exact[key] {
+		return false
+	}
+	self.exact[key] = true
+	if self.after > 0 && len(self.exact) > self.after {
+		self.toBloom()
+	}
+	return true
+}
+
+func (self *dedup) Len() int {
+	if self.bloom != nil {
+		return int(self.bloom.Count())
+	}
+	return len(self.exact)
+}
+
+// Save 将布隆过滤器写入文件，先写入临时文件再替换，失败时保留原文件
+func (self *dedup) Save(fileName string) error {
+	if self.bloom == nil {
+		return nil
+	}
+	tmp := fileName + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	_, err = self.bloom.WriteTo(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, fileName)
+}
+
+// Load 从文件读取布隆过滤器，未启用布隆过滤器或读取失败时返回false
+func (self *dedup) Load(fileName string) bool {
+	if self.after <= 0 {
+		return false
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	b, err := bloom.ReadScalable(bufio.NewReader(f))
+	if err != nil || b.FPRate() != self.fpRate && self.fpRate > 0 {
+		return false
+	}
+	self.bloom = b
+	self.exact = nil
+	return true
+}
+
+func (self *dedup) toBloom() {
+	capacity := uint64(bloomCapacity)
+	if n := uint64(len(self.exact)) * 2; n > capacity {
+		capacity = n
+	}
+	self.bloom = bloom.NewScalable(capacity, self.fpRate)
+	for key := range self.exact {
+		self.bloom.Add([]byte(key))
+	}
+	self.exact = nil
+}
+
+// Pending 本次运行中已加入队列、尚未完成的请求，用于去重，非并发安全，
+// 记录数较少时使用精确的map，超过阈值后转为支持删除的计数布隆过滤器
+type Pending struct {
+	exact  map[string]bool
+	bloom  *bloom.Counting
+	after  int
+	fpRate float64
+}
+
+// NewPending 根据全局配置创建未完成请求的记录
+func NewPending() *Pending {
+	return &Pending{
+		exact:  make(map[string]bool),
+		after:  cache.Task.BloomAfter,
+		fpRate: cache.Task.BloomRate,
+	}
+}
+
+// Has 检查是否存在（布隆过滤器模式下可能误判为存在）
+func (self *Pending) Has(key string) bool {
+	if self.bloom != nil {
+		return self.bloom.Has([]byte(key))
+	}
+	return self.exact[key]
+}
+
+// Add 加入记录，调用前应先以Has检查
+func (self *Pending) Add(key string) {
+	if self.bloom != nil {
+		self.bloom.Add([]byte(key))
+		return
+	}
+	self.exact[key] = true
+	if self.after > 0 && len(self.exact) > self.after {
+		capacity := uint64(bloomCapacity)
+		if n := uint64(len(self.exact)) * 2; n > capacity {
+			capacity = n
+		}
+		self.bloom = bloom.NewCounting(capacity, self.fpRate)
+		for key := range self.exact {
+			self.bloom.Add([]byte(key))
+		}
+		self.exact = nil
+	}
+}
+
+// Del 删除记录，请求完成时调用，仅可删除已加入的记录
+func (self *Pending) Del(key string) {
+	if self.bloom != nil {
+		self.bloom.Remove([]byte(key))
+		return
+	}
+	delete(self.exact, key)
+}
+
+// Len 返回记录数
+func (self *Pending) Len() int {
+	if self.bloom != nil {
+		return int(self.bloom.Count())
+	}
+	return len(self.exact)
+}
@@ -0,0 +1,117 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestDedupSaveLoad(t *testing.T) {
+	d := &dedup{exact: make(map[string]bool), after: 10, fpRate: 0.001}
+	for i := 0; i < 100; i++ {
+		if !d.Add(strconv.Itoa(i)) {
+			t.Fatalf("Add(%d) = false", i)
+		}
+	}
+	if d.bloom == nil || d.Len() != 100 || d.Add("1") {
+		t.Fatalf("bloom = %v, len = %d", d.bloom != nil, d.Len())
+	}
+
+	fileName := filepath.Join(t.TempDir(), "success.bloom")
+	if err := d.Save(fileName); err != nil {
+		t.Fatal(err)
+	}
+	loaded := &dedup{exact: make(map[string]bool), after: 10, fpRate: 0.001}
+	if !loaded.Load(fileName) || loaded.Len() != 100 || !loaded.Has("99") {
+		t.Fatalf("Load: len = %d", loaded.Len())
+	}
+	// 误判率不一致时不沿用
+	if (&dedup{exact: make(map[string]bool), after: 10, fpRate: 0.01}).Load(fileName) {
+		t.Fatal("Load with different fpRate")
+	}
+
+	// 写入失败时返回错误，且不留下临时文件
+	bad := filepath.Join(t.TempDir(), "missing", "success.bloom")
+	if err := d.Save(bad); err == nil {
+		t.Fatal("Save to missing dir should fail")
+	}
+	if _, err := os.Stat(bad + ".tmp"); !os.IsNotExist(err) {
+		t.Fatal("temp file left")
+	}
+}
+
+func TestPending(t *testing.T) {
+	p := &Pending{exact: make(map[string]bool), after: 10, fpRate: 0.001}
+	for i := 0; i < 100; i++ {
+		p.Add(strconv.Itoa(i))
+	}
+	if p.bloom == nil || p.Len() != 100 {
+		t.Fatalf("bloom = %v, len = %d", p.bloom != nil, p.Len())
+	}
+	for i := 0; i < 100; i += 2 {
+		p.Del(strconv.Itoa(i))
+	}
+	if p.Len() != 50 || !p.Has("1") || p.Has("0") {
+		t.Fatalf("len = %d", p.Len())
+	}
+}
+
+func TestSuccessBloomStamp(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "success")
+	newSuccess := func() *Success {
+		return &Success{
+			fileName:    fileName,
+			new:         make(map[string]bool),
+			old:         &dedup{exact: make(map[string]bool), after: 1, fpRate: 0.001},
+			inheritable: true,
+		}
+	}
+	s := newSuccess()
+	for _, k := range []string{"a", "b", "c"} {
+		s.UpsertSuccess(k)
+	}
+	if _, err := s.flush(""); err != nil {
+		t.Fatal(err)
+	}
+
+	// 历史记录未变时沿用布隆过滤器
+	if s2 := newSuccess(); !s2.loadBloom("") || !s2.old.Has("b") {
+		t.Fatal("bloom not loaded")
+	}
+
+	// 历史记录被修改后不再沿用，并删除布隆过滤器
+	f, _ := os.OpenFile(fileName, os.O_APPEND|os.O_WRONLY, 0666)
+	f.WriteString(`,"d":true`)
+	f.Close()
+	if newSuccess().loadBloom("") {
+		t.Fatal("stale bloom loaded")
+	}
+	if _, err := os.Stat(s.bloomFileName()); !os.IsNotExist(err) {
+		t.Fatal("stale bloom not removed")
+	}
+
+	// 不继承历史记录时的成功记录不写入布隆过滤器
+	s3 := newSuccess()
+	s3.inheritable = false
+	s3.old.Add("x")
+	s3.old.Add("y")
+	s3.UpsertSuccess("z")
+	s3.flush("")
+	if _, err := os.Stat(s3.bloomFileName()); !os.IsNotExist(err) {
+		t.Fatal("bloom saved without inheriting history")
+	}
+
+	// 重置历史记录时删除已持久化的布隆过滤器
+	s4 := newSuccess()
+	s4.UpsertSuccess("e")
+	s4.UpsertSuccess("f")
+	s4.flush("")
+	if _, err := os.Stat(s4.bloomFileName()); err != nil {
+		t.Fatal(err)
+	}
+	(&History{Success: newSuccess()}).ReadSuccess("", false)
+	if _, err := os.Stat(s4.bloomFileName()); !os.IsNotExist(err) {
+		t.Fatal("bloom not removed when history is reset")
+	}
+}
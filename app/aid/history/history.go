@@ -1,9 +1,12 @@
 package history
 
 import (
+	"bufio"
 	"encoding/json"
+	"io"
 	"io/ioutil"
 	"os"
+	"strings"
 	"sync"
 
 	"gopkg.in/mgo.v2/bson"
@@ -30,11 +33,17 @@ type (
 		DeleteFailure(*request.Request)            // 删除失败记录
 		FlushFailure(provider string)              // I/O输出失败记录，但不清缓存
 
+		OpenRevisit() error                            // 打开校验信息记录，开启增量抓取模式
+		GetRecord(reqUnique string) *Record            // 获取请求最近一次成功抓取时的校验信息
+		PutRecord(reqUnique string, rec *Record) error // 保存请求的校验信息
+		CloseRevisit() error                           // 关闭校验信息记录
+
 		Empty() // 清空缓存，但不输出
 	}
 	History struct {
 		*Success
 		*Failure
+		*Revisit
 		provider string
 		sync.RWMutex
 	}
@@ -45,6 +54,7 @@ const (
 	FAILURE_SUFFIX = config.HISTORY_TAG + "__n"
 	SUCCESS_FILE   = config.HISTORY_DIR + "/" + SUCCESS_SUFFIX
 	FAILURE_FILE   = config.HISTORY_DIR + "/" + FAILURE_SUFFIX
+	BLOOM_SUFFIX   = ".bloom"
 )
 
 func New(name string, subName string) Historier {
@@ -52,7 +62,9 @@ func New(name string, subName string) Historier {
 	successFileName := SUCCESS_FILE + "__" + name
 	failureTabName := FAILURE_SUFFIX + "__" + name
 	failureFileName := FAILURE_FILE + "__" + name
+	revisitName := REVISIT_SUFFIX + "__" + name
 	if subName != "" {
+		revisitName += "__" + subName
 		successTabName += "__" + subName
 		successFileName += "__" + subName
 		failureTabName += "__" + subName
@@ -63,13 +75,14 @@ func New(name string, subName string) Historier {
 			tabName:  util.FileNameReplace(successTabName),
 			fileName: successFileName,
 			new:      make(map[string]bool),
-			old:      make(map[string]bool),
+			old:      NewDeduper(),
 		},
 		Failure: &Failure{
 			tabName:  util.FileNameReplace(failureTabName),
 			fileName: failureFileName,
 			list:     make(map[string]*request.Request),
 		},
+		Revisit: newRevisit(revisitName),
 	}
 }
 
@@ -81,9 +94,11 @@ func (self *History) ReadSuccess(provider string, inherit bool) {
 
 	if !inherit {
 		// 不继承历史记录时
-		self.Success.old = make(map[string]bool)
+		self.Success.old = NewDeduper()
 		self.Success.new = make(map[string]bool)
 		self.Success.inheritable = false
+		// 布隆过滤器不再与历史记录一致
+		self.Success.removeBloom()
 		return
 
 	} else if self.Success.inheritable {
@@ -92,11 +107,17 @@ func (self *History) ReadSuccess(provider string, inherit bool) {
 
 	} else {
 		// 上次没有继承历史记录，但本次继承时
-		self.Success.old = make(map[string]bool)
+		self.Success.old = NewDeduper()
 		self.Success.new = make(map[string]bool)
 		self.Success.inheritable = true
 	}
 
+	// 优先读取已持久化且与历史记录一致的布隆过滤器
+	if self.Success.loadBloom(provider) {
+		logs.Log.Informational(" *     [读取成功记录][bloom]: %v 条\n", self.Success.old.Len())
+		return
+	}
+
 	switch provider {
 	case "mgo":
 		var docs = map[string]interface{}{}
@@ -109,7 +130,7 @@ func (self *History) ReadSuccess(provider string, inherit bool) {
 			return
 		}
 		for _, v := range docs["Docs"].([]interface{}) {
-			self.Success.old[v.(bson.M)["_id"].(string)] = true
+			self.Success.old.Add(v.(bson.M)["_id"].(string))
 		}
 
 	case "mysql":
@@ -131,7 +152,7 @@ func (self *History) ReadSuccess(provider string, inherit bool) {
 		for rows.Next() {
 			var id string
 			err = rows.Scan(&id)
-			self.Success.old[id] = true
+			self.Success.old.Add(id)
 		}
 
 	default:
@@ -140,14 +161,9 @@ func (self *History) ReadSuccess(provider string, inherit bool) {
 			return
 		}
 		defer f.Close()
-		b, _ := ioutil.ReadAll(f)
-		if len(b) == 0 {
-			return
-		}
-		b[0] = '{'
-		json.Unmarshal(append(b, '}'), &self.Success.old)
+		readSuccessFile(f, self.Success.old)
 	}
-	logs.Log.Informational(" *     [读取成功记录]: %v 条\n", len(self.Success.old))
+	logs.Log.Informational(" *     [读取成功记录]: %v 条\n", self.Success.old.Len())
 }
 
 // ReadFailure 取出失败记录
@@ -257,7 +273,7 @@ func (self *History) ReadFailure(provider string, inherit bool) {
 func (self *History) Empty() {
 	self.RWMutex.Lock()
 	self.Success.new = make(map[string]bool)
-	self.Success.old = make(map[string]bool)
+	self.Success.old = NewDeduper()
 	self.Failure.list = make(map[string]*request.Request)
 	self.RWMutex.Unlock()
 }
@@ -296,6 +312,32 @@ func (self *History) FlushFailure(provider string) {
 	}
 }
 
+// 流式读取成功记录文件，避免一次性载入整个文件，
+// 文件格式为多段以','开头、不含'}'的JSON对象内容。
+func readSuccessFile(f io.Reader, old Deduper) {
+	br := bufio.NewReader(f)
+	if _, err := br.ReadByte(); err != nil {
+		return
+	}
+	dec := json.NewDecoder(io.MultiReader(strings.NewReader("{"), br, strings.NewReader("}")))
+	if _, err := dec.Token(); err != nil {
+		return
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return
+		}
+		var ok bool
+		if err = dec.Decode(&ok); err != nil {
+			return
+		}
+		if key, _ := t.(string); ok && key != "" {
+			old.Add(key)
+		}
+	}
+}
+
 var (
 	readMysqlTable     = map[string]*mysql.MyTable{}
 	readMysqlTableLock sync.RWMutex
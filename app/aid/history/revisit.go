@@ -0,0 +1,104 @@
+package history
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/molast/crawler-core/common/buckets"
+	"github.com/molast/crawler-core/common/util"
+	"github.com/molast/crawler-core/config"
+)
+
+type (
+	// Record 请求最近一次成功抓取时的校验信息，用于增量抓取
+	Record struct {
+		ETag         string    // 响应头ETag
+		LastModified string    // 响应头Last-Modified
+		Hash         string    // 响应内容的md5，未读取内容时为空
+		Fetched      time.Time // 抓取时刻
+	}
+	// Revisit 增量抓取模式下各请求的校验信息，以boltDB文件保存于HISTORY_DIR
+	Revisit struct {
+		fileName string
+		db       *buckets.DB
+		bucket   *buckets.Bucket
+		sync.RWMutex
+	}
+)
+
+const REVISIT_SUFFIX = config.HISTORY_TAG + "__v"
+
+var revisitBucket = []byte(REVISIT_SUFFIX)
+
+func newRevisit(baseName string) *Revisit {
+	return &Revisit{
+		fileName: config.HISTORY_DIR + "/" + util.FileNameReplace(baseName) + ".db",
+	}
+}
+
+// OpenRevisit 打开校验信息记录，开启增量抓取模式
+func (self *Revisit) OpenRevisit() error {
+	self.Lock()
+	defer self.Unlock()
+	if self.db != nil {
+		return nil
+	}
+	db, err := buckets.Open(self.fileName)
+	if err != nil {
+		return err
+	}
+	bucket, err := db.New(revisitBucket)
+	if err != nil {
+		db.Close()
+		return err
+	}
+	self.db = db
+	self.bucket = bucket
+	return nil
+}
+
+// GetRecord 获取请求的校验信息，不存在或未开启增量抓取时返回nil
+func (self *Revisit) GetRecord(reqUnique string) *Record {
+	self.RLock()
+	defer self.RUnlock()
+	if self.bucket == nil {
+		return nil
+	}
+	b, err := self.bucket.Get([]byte(reqUnique))
+	if err != nil || b == nil {
+		return nil
+	}
+	rec := new(Record)
+	if json.Unmarshal(b, rec) != nil {
+		return nil
+	}
+	return rec
+}
+
+// PutRecord 保存请求的校验信息
+func (self *Revisit) PutRecord(reqUnique string, rec *Record) error {
+	self.RLock()
+	defer self.RUnlock()
+	if self.bucket == nil {
+		return nil
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return self.bucket.Put([]byte(reqUnique), b)
+}
+
+// CloseRevisit 关闭校验信息记录
+func (self *Revisit) CloseRevisit() error {
+	self.Lock()
+	defer self.Unlock()
+	if self.db == nil {
+		return nil
+	}
+	err := self.db.Close()
+	self.db = nil
+	self.bucket = nil
+	return err
+}
@@ -3,19 +3,22 @@ package history
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strconv"
 	"sync"
 
 	"github.com/molast/crawler-core/common/mgo"
 	"github.com/molast/crawler-core/common/mysql"
 	"github.com/molast/crawler-core/config"
+	"github.com/molast/crawler-core/logs"
 )
 
 type Success struct {
 	tabName     string
 	fileName    string
 	new         map[string]bool // [Request.Unique()]true
-	old         map[string]bool // [Request.Unique()]true
+	old         Deduper         // 已输出的Request.Unique()
 	inheritable bool
 	sync.RWMutex
 }
@@ -27,7 +30,7 @@ func (self *Success) UpsertSuccess(reqUnique string) bool {
 	self.RWMutex.Lock()
 	defer self.RWMutex.Unlock()
 
-	if self.old[reqUnique] {
+	if self.old.Has(reqUnique) {
 		return false
 	}
 	if self.new[reqUnique] {
@@ -39,7 +42,7 @@ func (self *Success) UpsertSuccess(reqUnique string) bool {
 
 func (self *Success) HasSuccess(reqUnique string) bool {
 	self.RWMutex.Lock()
-	has := self.old[reqUnique] || self.new[reqUnique]
+	has := self.old.Has(reqUnique) || self.new[reqUnique]
 	self.RWMutex.Unlock()
 	return has
 }
@@ -63,7 +66,7 @@ func (self *Success) flush(provider string) (sLen int, err error) {
 		var i int
 		for key := range self.new {
 			docs[i] = map[string]interface{}{"_id": key}
-			self.old[key] = true
+			self.old.Add(key)
 			i++
 		}
 		err := mgo.Mgo(nil, "insert", map[string]interface{}{
@@ -92,7 +95,7 @@ func (self *Success) flush(provider string) (sLen int, err error) {
 		}
 		for key := range self.new {
 			table.AutoInsert([]string{key})
-			self.old[key] = true
+			self.old.Add(key)
 		}
 		err = table.FlushInsert()
 		if err != nil {
@@ -108,9 +111,88 @@ func (self *Success) flush(provider string) (sLen int, err error) {
 		f.Close()
 
 		for key := range self.new {
-			self.old[key] = true
+			self.old.Add(key)
 		}
 	}
 	self.new = make(map[string]bool)
+	if self.inheritable {
+		self.saveBloom(provider)
+	}
 	return
 }
+
+// 布隆过滤器的持久化文件
+func (self *Success) bloomFileName() string {
+	return self.fileName + BLOOM_SUFFIX
+}
+
+// 布隆过滤器对应的历史记录规模的文件，内容为“provider 规模”
+func (self *Success) stampFileName() string {
+	return self.bloomFileName() + ".stamp"
+}
+
+// 历史记录的规模：文件为字节数，mgo、mysql为记录数，用于校验布隆过滤器与历史记录是否一致
+func (self *Success) historySize(provider string) (int64, error) {
+	switch provider {
+	case "mgo":
+		var n int
+		err := mgo.Mgo(&n, "count", map[string]interface{}{
+			"Database":   config.DB_NAME,
+			"Collection": self.tabName,
+		})
+		return int64(n), err
+	case "mysql":
+		db, err := mysql.DB()
+		if err != nil {
+			return 0, err
+		}
+		var n int64
+		err = db.QueryRow("SELECT COUNT(*) FROM `" + self.tabName + "`;").Scan(&n)
+		return n, err
+	default:
+		info, err := os.Stat(self.fileName)
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+}
+
+// 持久化布隆过滤器及对应的历史记录规模，无法获得规模时删除布隆过滤器，下次从历史记录重建
+func (self *Success) saveBloom(provider string) {
+	if d, ok := self.old.(*dedup); ok && d.bloom == nil {
+		return
+	}
+	size, err := self.historySize(provider)
+	if err == nil {
+		err = self.old.Save(self.bloomFileName())
+	}
+	if err == nil {
+		err = ioutil.WriteFile(self.stampFileName(), []byte(provider+" "+strconv.FormatInt(size, 10)), 0666)
+	}
+	if err != nil {
+		self.removeBloom()
+		logs.Log.Error(" *     Fail  [保存成功记录][bloom]: %v\n", err)
+	}
+}
+
+// 读取持久化的布隆过滤器，仅当其对应的历史记录规模与当前一致时沿用，否则删除
+func (self *Success) loadBloom(provider string) bool {
+	stamp, err := ioutil.ReadFile(self.stampFileName())
+	if err != nil {
+		self.removeBloom()
+		return false
+	}
+	size, err := self.historySize(provider)
+	if err != nil || string(stamp) != provider+" "+strconv.FormatInt(size, 10) || !self.old.Load(self.bloomFileName()) {
+		self.removeBloom()
+		return false
+	}
+	return true
+}
+
+// 删除持久化的布隆过滤器，如不继承历史记录或其与历史记录不一致时
+func (self *Success) removeBloom() {
+	os.Remove(self.bloomFileName())
+	os.Remove(self.stampFileName())
+}
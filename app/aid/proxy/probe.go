@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/molast/crawler-core/app/downloader/surfer"
+)
+
+// 健康检查的超时时长
+const probeTimeout = 10 * time.Second
+
+// 每隔interval经由各代理IP访问probeURL，失败的代理IP在下次检查通过前不再分配
+func (self *Proxy) probeLoop(probeURL string, interval time.Duration) {
+	for {
+		self.Probe(probeURL)
+		time.Sleep(interval)
+	}
+}
+
+// Probe 立即检查所有代理IP
+func (self *Proxy) Probe(probeURL string) {
+	self.RLock()
+	proxys := append([]string(nil), self.allProxyIps...)
+	self.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, p := range proxys {
+		wg.Add(1)
+		go func(p string) {
+			defer wg.Done()
+			start := time.Now()
+			ok := probe(p, probeURL)
+			self.Lock()
+			if e, found := self.entries[p]; found {
+				e.unhealthy = !ok
+				if ok && e.latency == 0 {
+					e.latency = time.Since(start)
+				}
+			}
+			self.Unlock()
+		}(p)
+	}
+	wg.Wait()
+}
+
+// 经由代理访问probeURL，返回状态码是否小于400
+func probe(proxy, probeURL string) bool {
+	// 与实际下载使用同一共享Transport（连接池配置、DNS缓存、SOCKS5支持）
+	transport, err := surfer.Transport(probeURL, proxy)
+	if err != nil {
+		return false
+	}
+	client := &http.Client{Transport: transport, Timeout: probeTimeout}
+	resp, err := client.Get(probeURL)
+	if err != nil {
+		return false
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode < 400
+}
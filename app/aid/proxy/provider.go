@@ -0,0 +1,188 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/molast/crawler-core/config"
+)
+
+// 代理IP的来源
+const (
+	FILE_PROVIDER   = "file"   // 本地文件
+	STATIC_PROVIDER = "static" // 配置中的静态列表
+	API_PROVIDER    = "api"    // 代理IP商提供的HTTP接口
+)
+
+// api来源的请求超时时长
+const apiTimeout = 30 * time.Second
+
+var (
+	// 支持http、https、socks5、socks5h代理，主机可为IP或域名，可带用户名及密码；
+	// 不带协议的ip:port视为http代理
+	proxyRegexp = regexp.MustCompile(`(?i)(?:https?|socks5h?)://(?:[^\s:@/]+(?::[^\s@/]*)?@)?(?:[\w.-]+|\[[0-9a-f:.]+\]):[0-9]+|\b\d{1,3}(?:\.\d{1,3}){3}:\d{2,5}\b`)
+)
+
+type (
+	// ProxyProvider 代理IP的来源，更新代理IP列表时调用
+	ProxyProvider interface {
+		// Proxys 返回最新的代理IP列表
+		Proxys() ([]string, error)
+	}
+	// FileProvider 从本地文件读取代理IP
+	FileProvider struct {
+		Path string
+	}
+	// StaticProvider 固定的代理IP列表
+	StaticProvider struct {
+		List []string
+	}
+	// ApiProvider 从代理IP商的HTTP接口获取代理IP，接口可返回文本或JSON
+	ApiProvider struct {
+		Url     string
+		Timeout time.Duration
+	}
+)
+
+// NewProvider 按配置创建代理IP的来源
+func NewProvider() ProxyProvider {
+	switch config.PROXY_PROVIDER {
+	case STATIC_PROVIDER:
+		return &StaticProvider{List: strings.Split(config.PROXY_LIST, ",")}
+	case API_PROVIDER:
+		return &ApiProvider{Url: config.PROXY_API, Timeout: apiTimeout}
+	default:
+		return &FileProvider{Path: config.PROXY}
+	}
+}
+
+// Proxys 读取文件中的代理IP
+func (self *FileProvider) Proxys() ([]string, error) {
+	b, err := ioutil.ReadFile(self.Path)
+	if err != nil {
+		return nil, err
+	}
+	return parseText(string(b)), nil
+}
+
+// Proxys 返回列表中的代理IP
+func (self *StaticProvider) Proxys() ([]string, error) {
+	return parseText(strings.Join(self.List, "\n")), nil
+}
+
+// Proxys 请求接口获取代理IP
+func (self *ApiProvider) Proxys() ([]string, error) {
+	if self.Url == "" {
+		return nil, errors.New("未设置代理IP接口地址")
+	}
+	client := &http.Client{Timeout: self.Timeout}
+	resp, err := client.Get(self.Url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("代理IP接口返回 %v", resp.Status)
+	}
+
+	var proxys []string
+	body := strings.TrimSpace(string(b))
+	if strings.HasPrefix(body, "{") || strings.HasPrefix(body, "[") {
+		var v interface{}
+		if err = json.Unmarshal(b, &v); err != nil {
+			return nil, err
+		}
+		proxys = parseJSON(v, nil)
+	} else {
+		proxys = parseText(body)
+	}
+	// 接口出错时常以正常状态码返回提示信息，此时保留原有代理IP
+	if len(proxys) == 0 {
+		return nil, fmt.Errorf("代理IP接口未返回代理IP: %.200s", body)
+	}
+	return proxys, nil
+}
+
+// 从文本中提取代理IP
+func parseText(text string) []string {
+	proxys := proxyRegexp.FindAllString(text, -1)
+	for i, p := range proxys {
+		if !strings.Contains(p, "://") {
+			proxys[i] = "http://" + p
+		}
+	}
+	return proxys
+}
+
+// 遍历JSON，提取字符串中的代理IP，以及形如{"ip":"","port":0,"scheme":"","user":"","password":""}的对象
+func parseJSON(v interface{}, proxys []string) []string {
+	switch v := v.(type) {
+	case string:
+		proxys = append(proxys, parseText(v)...)
+	case []interface{}:
+		for _, e := range v {
+			proxys = parseJSON(e, proxys)
+		}
+	case map[string]interface{}:
+		if p := jsonProxy(v); p != "" {
+			return append(proxys, p)
+		}
+		for _, e := range v {
+			proxys = parseJSON(e, proxys)
+		}
+	}
+	return proxys
+}
+
+// 由JSON对象的字段组成代理IP，字段不全时返回空
+func jsonProxy(m map[string]interface{}) string {
+	get := func(keys ...string) string {
+		for _, k := range keys {
+			for key, v := range m {
+				if !strings.EqualFold(key, k) {
+					continue
+				}
+				switch v := v.(type) {
+				case string:
+					return v
+				case float64:
+					return strconv.FormatInt(int64(v), 10)
+				}
+			}
+		}
+		return ""
+	}
+	host, port := get("ip", "host"), get("port")
+	if host == "" || port == "" {
+		return ""
+	}
+	scheme := strings.ToLower(get("scheme", "protocol", "type"))
+	switch scheme {
+	case "http", "https", "socks5", "socks5h":
+	case "socks":
+		scheme = "socks5"
+	default:
+		scheme = "http"
+	}
+	var auth string
+	if user := get("user", "username"); user != "" {
+		auth = user
+		if password := get("password", "pass"); password != "" {
+			auth += ":" + password
+		}
+		auth += "@"
+	}
+	return scheme + "://" + auth + net.JoinHostPort(host, port)
+}
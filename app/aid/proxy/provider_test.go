@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestApiProvider(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	cases := []struct {
+		body string
+		want []string
+	}{
+		{"1.2.3.4:8080\r\n5.6.7.8:3128\r\n", []string{"http://1.2.3.4:8080", "http://5.6.7.8:3128"}},
+		{"socks5://u:p@a.example.com:1080 https://b.example.com:443", []string{"socks5://u:p@a.example.com:1080", "https://b.example.com:443"}},
+		{`{"code":0,"data":[{"ip":"1.2.3.4","port":8080},{"host":"5.6.7.8","port":"1080","protocol":"SOCKS5","user":"u","password":"p"}]}`,
+			[]string{"http://1.2.3.4:8080", "socks5://u:p@5.6.7.8:1080"}},
+		{`["http://1.2.3.4:8080","9.9.9.9:80"]`, []string{"http://1.2.3.4:8080", "http://9.9.9.9:80"}},
+	}
+	provider := &ApiProvider{Url: srv.URL}
+	for i, c := range cases {
+		body = c.body
+		got, err := provider.Proxys()
+		if err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("case %d: got %v, %v, want %v", i, got, err, c.want)
+		}
+	}
+
+	// 接口返回错误提示时保留原有代理IP
+	p := newTestProxy(ROUND_ROBIN)
+	p.SetProvider(provider)
+	body = `{"code":1,"msg":"余额不足"}`
+	p.Update()
+	if p.Count() != 2 {
+		t.Fatalf("count = %d", p.Count())
+	}
+	p.SetProvider(&StaticProvider{List: []string{"socks5://1.1.1.1:1080", " 2.2.2.2:8080", ""}})
+	if p.Count() != 2 || p.GetOne("") != "socks5://1.1.1.1:1080" || p.GetOne("") != "http://2.2.2.2:8080" {
+		t.Fatalf("static: %v", p.Stats())
+	}
+}
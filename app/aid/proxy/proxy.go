@@ -1,25 +1,76 @@
 package proxy
 
 import (
-	"github.com/molast/crawler-core/config"
-	"io/ioutil"
 	"log"
+	"math/rand"
+	"net/url"
 	"os"
-	"regexp"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/molast/crawler-core/config"
 )
 
-// Proxy author: 代理模块基本重构 wj
-type Proxy struct {
-	proxyIPTypeRegexp *regexp.Regexp
-	allProxyIps       []string
-	ticker            *time.Ticker
-	tickSecond        int64
-}
+// 代理IP的选择策略
+const (
+	ROUND_ROBIN = "round-robin" // 轮流使用
+	LEAST_USED  = "least-used"  // 使用次数最少者优先
+	WEIGHTED    = "weighted"    // 按成功率加权随机
+)
+
+type (
+	// Proxy author: 代理模块基本重构 wj
+	// 代理IP池，支持多种选择策略、按主机固定分配、失败后暂时停用及健康检查
+	Proxy struct {
+		provider    ProxyProvider
+		allProxyIps []string
+		ticker      *time.Ticker // 定时更新代理IP列表，由锁保护
+		tickSecond  int64
+		updating    int32 // 是否正在更新代理IP列表，同一时刻只进行一次更新
+
+		strategy  string
+		sticky    bool
+		banAfter  int
+		banTime   time.Duration
+		entries   map[string]*entry // [代理IP]状态
+		hosts     map[string]string // [主机]固定分配的代理IP
+		next      int               // 轮流使用的下一个索引
+		probeOnce sync.Once
+		sync.RWMutex
+	}
+	// 单个代理IP的状态
+	entry struct {
+		used        int64
+		success     int64
+		failure     int64
+		fails       int           // 连续失败次数
+		latency     time.Duration // 平均响应时长
+		bannedUntil time.Time     // 暂时停用至该时刻
+		unhealthy   bool          // 最近一次健康检查失败
+	}
+	// Stat 单个代理IP的统计信息，用于监控
+	Stat struct {
+		Proxy       string
+		Used        int64         // 分配次数
+		Success     int64         // 成功次数
+		Failure     int64         // 失败次数
+		Latency     time.Duration // 平均响应时长
+		BannedUntil time.Time     // 暂时停用至该时刻，零值为未停用
+		Healthy     bool          // 最近一次健康检查是否通过
+	}
+)
 
 func New() *Proxy {
 	p := &Proxy{
-		proxyIPTypeRegexp: regexp.MustCompile(`https?://([\w]*:[\w]*@)?[0-9]+\.[0-9]+\.[0-9]+\.[0-9]+:[0-9]+`),
+		provider: NewProvider(),
+		strategy: config.PROXY_STRATEGY,
+		sticky:   config.PROXY_STICKY,
+		banAfter: config.PROXY_BAN_AFTER,
+		banTime:  time.Duration(config.PROXY_BAN_SECOND) * time.Second,
+		entries:  make(map[string]*entry),
+		hosts:    make(map[string]string),
 	}
 	go p.Update()
 	return p
@@ -27,70 +78,249 @@ func New() *Proxy {
 
 // Count 代理IP数量
 func (self *Proxy) Count() int32 {
+	self.RLock()
+	defer self.RUnlock()
 	return int32(len(self.allProxyIps))
 }
 
-// 更新代理IP列表
-/*func (self *Proxy) Update() *Proxy {
-	allProxyIps,err := self.ProxyInfo()
-	if err != nil {
-		log.Printf("代理读取错误：%s", err.Error())
-		return self
-	}
+// SetProvider 更换代理IP的来源，并立即更新代理IP列表
+func (self *Proxy) SetProvider(provider ProxyProvider) *Proxy {
+	self.Lock()
+	self.provider = provider
+	self.Unlock()
+	return self.Update()
+}
 
-	self.allProxyIps = allProxyIps
-	if len(self.allProxyIps)<=0{
+// Update 从代理IP的来源更新代理IP列表，获取失败时保留原有列表，正在更新时直接返回
+func (self *Proxy) Update() *Proxy {
+	if !atomic.CompareAndSwapInt32(&self.updating, 0, 1) {
 		return self
 	}
-	log.Printf(" *     读取代理IP: %v 条\n", len(self.allProxyIps))
+	defer atomic.StoreInt32(&self.updating, 0)
+	self.update()
 	return self
-}*/
+}
 
-// Update 更新代理IP列表
-func (self *Proxy) Update() *Proxy {
-	f, err := os.Open(config.PROXY)
+// 执行更新，调用前须已将updating置为1
+func (self *Proxy) update() {
+	self.RLock()
+	provider := self.provider
+	self.RUnlock()
+	if provider == nil {
+		return
+	}
+	proxys, err := provider.Proxys()
 	if err != nil {
-		// logs.Log.Error("Error: %v\n", err)
-		return self
+		if !os.IsNotExist(err) {
+			log.Printf(" *     更新代理IP失败: %v\n", err)
+		}
+		return
 	}
-	b, _ := ioutil.ReadAll(f)
-	f.Close()
+	self.Set(proxys)
+	log.Printf(" *     读取代理IP: %v 条\n", self.Count())
+}
 
-	proxysIPType := self.proxyIPTypeRegexp.FindAllString(string(b), -1)
-	for _, proxy := range proxysIPType {
-		self.allProxyIps = append(self.allProxyIps, proxy)
+// Set 替换代理IP列表，保留仍在列表中的代理IP的统计信息
+func (self *Proxy) Set(proxys []string) {
+	self.Lock()
+	defer self.Unlock()
+	entries := make(map[string]*entry, len(proxys))
+	all := make([]string, 0, len(proxys))
+	for _, p := range proxys {
+		if _, ok := entries[p]; ok {
+			continue
+		}
+		if e, ok := self.entries[p]; ok {
+			entries[p] = e
+		} else {
+			entries[p] = new(entry)
+		}
+		all = append(all, p)
 	}
-	log.Printf(" *     读取代理IP: %v 条\n", len(self.allProxyIps))
-	return self
+	for host, p := range self.hosts {
+		if _, ok := entries[p]; !ok {
+			delete(self.hosts, host)
+		}
+	}
+	self.entries = entries
+	self.allProxyIps = all
 }
 
-// UpdateTicker 更新继时器
+// UpdateTicker 更新继时器（停止原有的计时器），并开始健康检查
 func (self *Proxy) UpdateTicker(tickSecond int64) {
+	self.Lock()
+	if self.ticker != nil {
+		self.ticker.Stop()
+		self.ticker = nil
+	}
 	self.tickSecond = tickSecond
-	self.ticker = time.NewTicker(time.Duration(self.tickSecond) * time.Second)
+	if tickSecond > 0 {
+		self.ticker = time.NewTicker(time.Duration(tickSecond) * time.Second)
+	}
+	self.Unlock()
+	if config.PROXY_PROBE_URL != "" && config.PROXY_PROBE_SECOND > 0 {
+		self.probeOnce.Do(func() {
+			go self.probeLoop(config.PROXY_PROBE_URL, time.Duration(config.PROXY_PROBE_SECOND)*time.Second)
+		})
+	}
 }
 
-// 获取本次循环中未使用的代理IP及其响应时长
-var count = 0
-
+// GetOne 按选择策略返回一个可用的代理IP，无可用代理IP时返回空（使用本机IP）
 func (self *Proxy) GetOne(u string) (curProxy string) {
+	var host string
+	if self.sticky {
+		if URL, err := url.Parse(u); err == nil {
+			host = URL.Host
+		}
+	}
 
-	select {
-	case <-self.ticker.C:
-		self.allProxyIps = []string{}
-		self.Update()
+	self.Lock()
+	defer self.Unlock()
+	if self.ticker != nil {
+		select {
+		case <-self.ticker.C:
+			// 接口来源可能较慢，不阻塞请求的分配；上次更新尚未完成时跳过
+			if atomic.CompareAndSwapInt32(&self.updating, 0, 1) {
+				go func() {
+					defer atomic.StoreInt32(&self.updating, 0)
+					self.update()
+				}()
+			}
+		default:
+		}
+	}
+	now := time.Now()
+	if host != "" {
+		if p, ok := self.hosts[host]; ok && self.entries[p].usable(now) {
+			self.entries[p].used++
+			return p
+		}
+	}
+	candidates := make([]string, 0, len(self.allProxyIps))
+	for _, p := range self.allProxyIps {
+		if self.entries[p].usable(now) {
+			candidates = append(candidates, p)
+		}
+	}
+	if len(candidates) == 0 {
+		return "" //没有代理则使用本机ip
+	}
+
+	switch self.strategy {
+	case LEAST_USED:
+		curProxy = candidates[0]
+		for _, p := range candidates[1:] {
+			if self.entries[p].used < self.entries[curProxy].used {
+				curProxy = p
+			}
+		}
+	case WEIGHTED:
+		var total float64
+		weights := make([]float64, len(candidates))
+		for i, p := range candidates {
+			weights[i] = self.entries[p].weight()
+			total += weights[i]
+		}
+		r := rand.Float64() * total
+		curProxy = candidates[len(candidates)-1]
+		for i, w := range weights {
+			if r < w {
+				curProxy = candidates[i]
+				break
+			}
+			r -= w
+		}
 	default:
+		if self.next >= len(candidates) {
+			self.next = 0
+		}
+		curProxy = candidates[self.next]
+		self.next++
 	}
 
-	if len(self.allProxyIps) <= 0 {
-		return "" //没有代理则使用本机ip
+	self.entries[curProxy].used++
+	if host != "" {
+		self.hosts[host] = curProxy
+	}
+	return curProxy
+}
+
+// Report 反馈代理IP的使用结果，连续失败达到指定次数时暂时停用
+func (self *Proxy) Report(proxy string, ok bool, latency time.Duration) {
+	self.Lock()
+	defer self.Unlock()
+	e, found := self.entries[proxy]
+	if !found {
+		return
+	}
+	if ok {
+		e.success++
+		e.fails = 0
+		if e.latency == 0 {
+			e.latency = latency
+		} else {
+			e.latency = (e.latency*7 + latency) / 8
+		}
+		return
 	}
-	if count >= len(self.allProxyIps) {
-		count = 0
+	e.failure++
+	e.fails++
+	if self.banAfter > 0 && e.fails >= self.banAfter {
+		self.ban(proxy, e, self.banTime)
 	}
-	//TODO 可自身根据需要设计算法随机获取代理
-	s := self.allProxyIps[count]
-	count++
+}
+
+// Ban 暂时停用代理IP，如检测到该代理IP已被目标网站封禁时
+func (self *Proxy) Ban(proxy string, d time.Duration) {
+	self.Lock()
+	defer self.Unlock()
+	if e, ok := self.entries[proxy]; ok {
+		self.ban(proxy, e, d)
+	}
+}
+
+func (self *Proxy) ban(proxy string, e *entry, d time.Duration) {
+	e.bannedUntil = time.Now().Add(d)
+	e.fails = 0
+	for host, p := range self.hosts {
+		if p == proxy {
+			delete(self.hosts, host)
+		}
+	}
+	log.Printf(" *     代理IP %v 暂时停用 %v\n", proxy, d)
+}
+
+// Stats 返回各代理IP的统计信息
+func (self *Proxy) Stats() []Stat {
+	self.RLock()
+	defer self.RUnlock()
+	now := time.Now()
+	stats := make([]Stat, 0, len(self.allProxyIps))
+	for _, p := range self.allProxyIps {
+		e := self.entries[p]
+		stat := Stat{
+			Proxy:   p,
+			Used:    e.used,
+			Success: e.success,
+			Failure: e.failure,
+			Latency: e.latency,
+			Healthy: !e.unhealthy,
+		}
+		if e.bannedUntil.After(now) {
+			stat.BannedUntil = e.bannedUntil
+		}
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Proxy < stats[j].Proxy })
+	return stats
+}
+
+// 是否可分配
+func (self *entry) usable(now time.Time) bool {
+	return self != nil && !self.unhealthy && !now.Before(self.bannedUntil)
+}
 
-	return s
+// 加权随机时的权重，按平滑后的成功率计算
+func (self *entry) weight() float64 {
+	return float64(self.success+1) / float64(self.success+self.failure+2)
 }
@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestProxy(strategy string, proxys ...string) *Proxy {
+	p := &Proxy{
+		strategy: strategy,
+		banAfter: 2,
+		banTime:  time.Hour,
+		entries:  make(map[string]*entry),
+		hosts:    make(map[string]string),
+	}
+	p.Set(proxys)
+	return p
+}
+
+func TestProxyPool(t *testing.T) {
+	a, b, c := "http://a.example.com:8080", "socks5://b.example.com:1080", "http://10.0.0.3:3128"
+
+	// 轮流使用，连续失败后暂时停用
+	p := newTestProxy(ROUND_ROBIN, a, b, c)
+	for i, want := range []string{a, b, c, a} {
+		if got := p.GetOne("http://x.com/"); got != want {
+			t.Fatalf("round-robin %d: got %v, want %v", i, got, want)
+		}
+	}
+	p.Report(b, false, 0)
+	p.Report(b, false, 0)
+	for i := 0; i < 4; i++ {
+		if p.GetOne("http://x.com/") == b {
+			t.Fatal("banned proxy returned")
+		}
+	}
+	for _, stat := range p.Stats() {
+		if stat.Proxy == b && (stat.Failure != 2 || stat.BannedUntil.IsZero()) {
+			t.Fatalf("stat = %+v", stat)
+		}
+	}
+
+	// 使用次数最少者优先
+	p = newTestProxy(LEAST_USED, a, b)
+	p.GetOne("")
+	p.GetOne("")
+	p.GetOne("")
+	if p.entries[a].used != 2 || p.entries[b].used != 1 {
+		t.Fatalf("least-used: a=%d b=%d", p.entries[a].used, p.entries[b].used)
+	}
+
+	// 按成功率加权
+	p = newTestProxy(WEIGHTED, a, b)
+	for i := 0; i < 50; i++ {
+		p.Report(a, true, time.Millisecond)
+	}
+	p.Report(b, false, 0)
+	var hitsA int
+	for i := 0; i < 200; i++ {
+		if p.GetOne("") == a {
+			hitsA++
+		}
+	}
+	if hitsA < 120 {
+		t.Fatalf("weighted: a chosen %d/200 times", hitsA)
+	}
+
+	// 同一主机固定使用同一代理IP，停用后重新分配
+	p = newTestProxy(ROUND_ROBIN, a, b, c)
+	p.sticky = true
+	first := p.GetOne("http://x.com/1")
+	p.GetOne("http://y.com/1")
+	if got := p.GetOne("http://x.com/2"); got != first {
+		t.Fatalf("sticky: got %v, want %v", got, first)
+	}
+	p.Ban(first, time.Hour)
+	if got := p.GetOne("http://x.com/3"); got == first || got == "" {
+		t.Fatalf("sticky after ban: got %v", got)
+	}
+
+	// 更新列表时保留统计信息
+	p.Set([]string{a, c})
+	if p.Count() != 2 || p.entries[a].used == 0 {
+		t.Fatalf("count = %d", p.Count())
+	}
+}
+
+func TestProxyProbe(t *testing.T) {
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer good.Close()
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	bad.Close()
+
+	p := newTestProxy(ROUND_ROBIN, good.URL, bad.URL)
+	p.Probe("http://probe.example.com/")
+	for i := 0; i < 3; i++ {
+		if got := p.GetOne(""); got != good.URL {
+			t.Fatalf("got %v, want %v", got, good.URL)
+		}
+	}
+	stats := p.Stats()
+	for _, stat := range stats {
+		if stat.Healthy != (stat.Proxy == good.URL) {
+			t.Fatalf("stat = %+v", stat)
+		}
+	}
+}
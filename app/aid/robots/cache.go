@@ -0,0 +1,167 @@
+package robots
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/molast/crawler-core/logs"
+)
+
+const (
+	maxBodySize       = 512 << 10   // robots.txt内容的最大读取长度
+	serverErrorExpire = time.Minute // 服务端错误（5xx）时禁止抓取的缓存有效期，到期后重新下载
+)
+
+// 服务端错误时按RFC 9309视为禁止抓取全部内容
+var disallowAll = Parse([]byte("User-agent: *\nDisallow: /"))
+
+// Cache 以主机为键缓存robots.txt，同一主机并发请求时只下载一次
+type (
+	Cache struct {
+		agent  string
+		expire time.Duration
+		client *http.Client
+		hosts  map[string]*entry
+		sync.Mutex
+	}
+	entry struct {
+		robots  *Robots
+		fetched time.Time
+		expire  time.Duration
+		ready   chan struct{}
+	}
+)
+
+// NewCache 创建robots.txt缓存
+// agent为匹配规则时使用的User-agent标识，expire为缓存有效期，
+// transport为下载robots.txt所用的连接（如经代理IP），为nil时直连
+func NewCache(agent string, timeout, expire time.Duration, transport http.RoundTripper) *Cache {
+	return &Cache{
+		agent:  agent,
+		expire: expire,
+		client: &http.Client{Timeout: timeout, Transport: transport},
+		hosts:  make(map[string]*entry),
+	}
+}
+
+// Ready 返回url所属主机的robots.txt是否已下载且未过期，为true时Allowed及CrawlDelay不会阻塞
+func (self *Cache) Ready(rawurl string) bool {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return true
+	}
+	key, ok := cacheKey(u)
+	if !ok {
+		return true
+	}
+	self.Lock()
+	defer self.Unlock()
+	e, ok := self.hosts[key]
+	if !ok {
+		return false
+	}
+	select {
+	case <-e.ready:
+		return e.fresh()
+	default:
+		return false
+	}
+}
+
+// Allowed 检查url是否被其所属主机的robots.txt允许抓取
+func (self *Cache) Allowed(rawurl string) bool {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return true
+	}
+	return self.get(u).Allowed(self.agent, u.RequestURI())
+}
+
+// CrawlDelay 返回url所属主机的robots.txt中声明的抓取间隔
+func (self *Cache) CrawlDelay(rawurl string) time.Duration {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return 0
+	}
+	return self.get(u).CrawlDelay(self.agent)
+}
+
+func (self *Cache) get(u *url.URL) *Robots {
+	key, ok := cacheKey(u)
+	if !ok {
+		return nil
+	}
+
+	self.Lock()
+	e, ok := self.hosts[key]
+	if ok {
+		select {
+		case <-e.ready:
+			ok = e.fresh()
+		default:
+		}
+	}
+	if !ok {
+		e = &entry{ready: make(chan struct{}), expire: self.expire}
+		self.hosts[key] = e
+		self.Unlock()
+		var serverError bool
+		e.robots, serverError = self.fetch(key + "/robots.txt")
+		if serverError && (e.expire <= 0 || e.expire > serverErrorExpire) {
+			e.expire = serverErrorExpire
+		}
+		e.fetched = time.Now()
+		close(e.ready)
+		return e.robots
+	}
+	self.Unlock()
+	<-e.ready
+	return e.robots
+}
+
+// 已下载的robots.txt是否仍在有效期内
+func (self *entry) fresh() bool {
+	return self.expire <= 0 || time.Since(self.fetched) <= self.expire
+}
+
+// 以协议及主机为缓存键，仅http及https需遵守robots.txt
+func cacheKey(u *url.URL) (string, bool) {
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return "", false
+	}
+	return scheme + "://" + strings.ToLower(u.Host), true
+}
+
+// 下载并解析robots.txt，
+// 不存在(4xx)或无法连接时视为不做任何限制，服务端错误(5xx)时视为禁止抓取全部内容。
+func (self *Cache) fetch(robotsUrl string) (robots *Robots, serverError bool) {
+	req, err := http.NewRequest("GET", robotsUrl, nil)
+	if err != nil {
+		return nil, false
+	}
+	req.Header.Set("User-Agent", self.agent)
+	resp, err := self.client.Do(req)
+	if err != nil {
+		logs.Log.Warning(" *     [robots.txt][%v]: %v\n", robotsUrl, err)
+		return nil, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		logs.Log.Warning(" *     [robots.txt][%v]: %v，暂时禁止抓取\n", robotsUrl, resp.Status)
+		return disallowAll, true
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		logs.Log.Warning(" *     [robots.txt][%v]: %v\n", robotsUrl, err)
+		return nil, false
+	}
+	return Parse(b), false
+}
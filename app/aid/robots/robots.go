@@ -0,0 +1,173 @@
+package robots
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Robots 解析后的robots.txt规则
+type (
+	Robots struct {
+		groups []*group
+	}
+	group struct {
+		agents []string      // 小写的User-agent列表
+		rules  []rule        // Allow/Disallow规则
+		delay  time.Duration // Crawl-delay
+	}
+	rule struct {
+		allow   bool
+		pattern string
+	}
+)
+
+// Parse 解析robots.txt内容
+func Parse(body []byte) *Robots {
+	var (
+		self    = &Robots{}
+		cur     *group
+		inRules bool // 当前分组是否已出现规则行，此后的User-agent将开启新分组
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		value := strings.TrimSpace(line[i+1:])
+
+		switch key {
+		case "user-agent":
+			if cur == nil || inRules {
+				cur = &group{}
+				self.groups = append(self.groups, cur)
+				inRules = false
+			}
+			cur.agents = append(cur.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			if cur == nil {
+				continue
+			}
+			inRules = true
+			// 空的Disallow表示允许全部
+			if value == "" {
+				continue
+			}
+			cur.rules = append(cur.rules, rule{allow: key == "allow", pattern: value})
+		case "crawl-delay":
+			if cur == nil {
+				continue
+			}
+			inRules = true
+			if sec, err := strconv.ParseFloat(value, 64); err == nil && sec > 0 {
+				cur.delay = time.Duration(sec * float64(time.Second))
+			}
+		}
+	}
+	return self
+}
+
+// Allowed 检查指定User-agent是否允许抓取path（含查询参数）
+func (self *Robots) Allowed(agent, path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+	g := self.group(agent)
+	if g == nil {
+		return true
+	}
+	// 最长匹配规则优先，长度相同时Allow优先
+	var (
+		matched bool
+		allow   = true
+		length  = -1
+	)
+	for _, r := range g.rules {
+		if !match(r.pattern, path) {
+			continue
+		}
+		l := len(r.pattern)
+		if l > length || l == length && r.allow && !allow {
+			matched, allow, length = true, r.allow, l
+		}
+	}
+	return !matched || allow
+}
+
+// CrawlDelay 返回指定User-agent的抓取间隔，未设置时为0
+func (self *Robots) CrawlDelay(agent string) time.Duration {
+	g := self.group(agent)
+	if g == nil {
+		return 0
+	}
+	return g.delay
+}
+
+// 查找与User-agent最为匹配的分组，无匹配时使用"*"分组
+func (self *Robots) group(agent string) *group {
+	if self == nil {
+		return nil
+	}
+	agent = strings.ToLower(agent)
+	var (
+		best    *group
+		bestLen int
+		star    *group
+	)
+	for _, g := range self.groups {
+		for _, a := range g.agents {
+			if a == "*" {
+				if star == nil {
+					star = g
+				}
+				continue
+			}
+			if a != "" && strings.Contains(agent, a) && len(a) > bestLen {
+				best, bestLen = g, len(a)
+			}
+		}
+	}
+	if best != nil {
+		return best
+	}
+	return star
+}
+
+// 匹配路径规则，支持通配符"*"与结尾符"$"
+func match(pattern, path string) bool {
+	end := strings.HasSuffix(pattern, "$")
+	if end {
+		pattern = pattern[:len(pattern)-1]
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for i := 1; i < len(parts); i++ {
+		p := parts[i]
+		if end && i == len(parts)-1 {
+			return len(path)-len(p) >= pos && strings.HasSuffix(path, p)
+		}
+		idx := strings.Index(path[pos:], p)
+		if idx < 0 {
+			return false
+		}
+		pos += idx + len(p)
+	}
+	if end {
+		return pos == len(path)
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package robots
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const robotsTxt = `
+# comment
+User-agent: *
+Disallow: /private/
+Allow: /private/public
+Disallow: /*.pdf$
+Crawl-delay: 1.5
+
+User-agent: crawler
+User-agent: other
+Disallow: /search
+Allow: /
+`
+
+func TestParse(t *testing.T) {
+	r := Parse([]byte(robotsTxt))
+	cases := []struct {
+		agent, path string
+		allowed     bool
+	}{
+		{"Mozilla", "/", true},
+		{"Mozilla", "/private/a", false},
+		{"Mozilla", "/private/public/a", true},
+		{"Mozilla", "/doc/a.pdf", false},
+		{"Mozilla", "/doc/a.pdf?x=1", true},
+		{"Mozilla", "/robots.txt", true},
+		{"my-crawler/1.0", "/private/a", true},
+		{"my-crawler/1.0", "/search?q=1", false},
+	}
+	for _, c := range cases {
+		if got := r.Allowed(c.agent, c.path); got != c.allowed {
+			t.Errorf("Allowed(%q, %q) = %v, want %v", c.agent, c.path, got, c.allowed)
+		}
+	}
+	if d := r.CrawlDelay("Mozilla"); d != 1500*time.Millisecond {
+		t.Errorf("CrawlDelay = %v", d)
+	}
+	if d := r.CrawlDelay("crawler"); d != 0 {
+		t.Errorf("CrawlDelay = %v", d)
+	}
+}
+
+func TestCache(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte("User-agent: *\nDisallow: /admin\nCrawl-delay: 2\n"))
+	}))
+	defer srv.Close()
+
+	c := NewCache("crawler", time.Second, time.Hour, nil)
+	if c.Ready(srv.URL + "/index.html") {
+		t.Error("robots.txt should not be ready before fetching")
+	}
+	if !c.Allowed(srv.URL + "/index.html") {
+		t.Error("index should be allowed")
+	}
+	if c.Allowed(srv.URL + "/admin/users") {
+		t.Error("admin should be disallowed")
+	}
+	if d := c.CrawlDelay(srv.URL + "/"); d != 2*time.Second {
+		t.Errorf("CrawlDelay = %v", d)
+	}
+	if n := atomic.LoadInt32(&hits); n != 1 || !c.Ready(srv.URL+"/other") {
+		t.Errorf("robots.txt fetched %d times", n)
+	}
+	// 不存在robots.txt的主机不做限制
+	if !NewCache("crawler", time.Second, time.Hour, nil).Allowed("http://127.0.0.1:1/admin") {
+		t.Error("unreachable host should be allowed")
+	}
+
+	// 服务端错误时禁止抓取全部内容
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	if NewCache("crawler", time.Second, time.Hour, nil).Allowed(down.URL + "/index.html") {
+		t.Error("5xx should disallow all")
+	}
+}
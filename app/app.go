@@ -8,8 +8,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/molast/crawler-core/app/aid/proxy"
 	"github.com/molast/crawler-core/app/crawler"
 	"github.com/molast/crawler-core/app/distribute"
+	"github.com/molast/crawler-core/app/downloader/respcache"
+	"github.com/molast/crawler-core/app/downloader/surfer"
 	"github.com/molast/crawler-core/app/pipeline"
 	"github.com/molast/crawler-core/app/pipeline/collector"
 	"github.com/molast/crawler-core/app/scheduler"
@@ -42,6 +45,9 @@ type (
 		GetSpiderQueue() crawler.SpiderQueue                          // 获取蜘蛛队列接口实例
 		GetOutputLib() []string                                       // 获取全部输出方式
 		GetTaskJar() *distribute.TaskJar                              // 返回任务库
+		AutoThrottleStats() []scheduler.AutoThrottleStat              // 返回各蜘蛛自适应并发的当前状态
+		ProxyStats() []proxy.Stat                                     // 返回代理IP池中各代理IP的统计信息
+		DnsStat() surfer.DnsStat                                      // 返回DNS缓存的统计信息
 		distribute.Distributer                                        // 实现分布式接口
 	}
 	Logic struct {
@@ -243,6 +249,21 @@ func (self *Logic) GetTaskJar() *distribute.TaskJar {
 	return self.TaskJar
 }
 
+// AutoThrottleStats 返回各蜘蛛自适应并发的当前状态，未开启自适应并发时为空
+func (self *Logic) AutoThrottleStats() []scheduler.AutoThrottleStat {
+	return scheduler.AutoThrottleStats()
+}
+
+// ProxyStats 返回代理IP池中各代理IP的统计信息
+func (self *Logic) ProxyStats() []proxy.Stat {
+	return scheduler.ProxyStats()
+}
+
+// DnsStat 返回DNS缓存的统计信息
+func (self *Logic) DnsStat() surfer.DnsStat {
+	return surfer.GetDnsStat()
+}
+
 // CountNodes 服务器客户端模式下返回节点数
 func (self *Logic) CountNodes() int {
 	return self.Teleport.CountNodes()
@@ -496,6 +517,8 @@ func (self *Logic) exec() {
 	pipeline.RefreshOutput()
 	// 初始化资源队列
 	scheduler.Init()
+	// 初始化响应缓存
+	respcache.Init()
 
 	// 设置爬虫队列
 	crawlerCap := self.CrawlerPool.Reset(count)
@@ -644,8 +667,22 @@ func (self *Logic) setAppConf(task *distribute.Task) {
 	self.AppConf.DockerCap = task.DockerCap
 	self.AppConf.SuccessInherit = task.SuccessInherit
 	self.AppConf.FailureInherit = task.FailureInherit
+	self.AppConf.FrontierInherit = task.FrontierInherit
 	self.AppConf.Limit = task.Limit
 	self.AppConf.ProxySecond = task.ProxySecond
+	self.AppConf.HostRate = task.HostRate
+	self.AppConf.HostBurst = task.HostBurst
+	self.AppConf.HostConns = task.HostConns
+	self.AppConf.HostByIP = task.HostByIP
+	self.AppConf.BloomAfter = task.BloomAfter
+	self.AppConf.BloomRate = task.BloomRate
+	self.AppConf.AutoThrottle = task.AutoThrottle
+	self.AppConf.AutoMinThread = task.AutoMinThread
+	self.AppConf.AutoMaxThread = task.AutoMaxThread
+	self.AppConf.AutoMinDelay = task.AutoMinDelay
+	self.AppConf.AutoMaxDelay = task.AutoMaxDelay
+	self.AppConf.CacheMode = task.CacheMode
+	self.AppConf.CacheExpire = task.CacheExpire
 	self.AppConf.Keyins = task.Keyins
 }
 
@@ -656,7 +693,21 @@ func (self *Logic) setTask(task *distribute.Task) {
 	task.DockerCap = self.AppConf.DockerCap
 	task.SuccessInherit = self.AppConf.SuccessInherit
 	task.FailureInherit = self.AppConf.FailureInherit
+	task.FrontierInherit = self.AppConf.FrontierInherit
 	task.Limit = self.AppConf.Limit
 	task.ProxySecond = self.AppConf.ProxySecond
+	task.HostRate = self.AppConf.HostRate
+	task.HostBurst = self.AppConf.HostBurst
+	task.HostConns = self.AppConf.HostConns
+	task.HostByIP = self.AppConf.HostByIP
+	task.BloomAfter = self.AppConf.BloomAfter
+	task.BloomRate = self.AppConf.BloomRate
+	task.AutoThrottle = self.AppConf.AutoThrottle
+	task.AutoMinThread = self.AppConf.AutoMinThread
+	task.AutoMaxThread = self.AppConf.AutoMaxThread
+	task.AutoMinDelay = self.AppConf.AutoMinDelay
+	task.AutoMaxDelay = self.AppConf.AutoMaxDelay
+	task.CacheMode = self.AppConf.CacheMode
+	task.CacheExpire = self.AppConf.CacheExpire
 	task.Keyins = self.AppConf.Keyins
 }
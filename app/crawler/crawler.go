@@ -3,6 +3,7 @@ package crawler
 import (
 	"bytes"
 	"math/rand"
+	"net/http"
 	"runtime"
 	"time"
 
@@ -108,6 +109,7 @@ func (self *crawler) run() {
 		go func() {
 			defer func() {
 				self.FreeOne()
+				self.DoneOne(req)
 			}()
 			logs.Log.Debug(" *     Start: %v", req.GetUrl())
 			self.Process(req)
@@ -133,8 +135,16 @@ func (self *crawler) Process(req *request.Request) {
 				// println("Process$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$")
 				return
 			}
-			// 返回是否作为新的失败请求被添加至队列尾部
-			if sp.DoHistory(req, false) {
+			// 文件输出时下载中断，按下载错误分类重试
+			if e, ok := p.(*spider.TransferError); ok {
+				if sp.DoFailure(req, request.Classify(e.Err, 0)) {
+					cache.PageFailCount()
+				}
+				logs.Log.Error(" *     Fail  [download][%v]: %v\n", downUrl, e)
+				return
+			}
+			// 返回是否为该请求的首次失败
+			if sp.DoFailure(req, request.RETRY_PANIC) {
 				// 统计失败数
 				cache.PageFailCount()
 			}
@@ -153,21 +163,71 @@ func (self *crawler) Process(req *request.Request) {
 		}
 	}()
 
-	/*s := getProxyHost()
-	if s != "" {
-		req.SetProxy(s)
-	}*/
-
+	var start = time.Now()
+	var session = sp.SessionGeneration()
 	var ctx = self.Downloader.Download(sp, req) // download page
 
-	if err := ctx.GetError(); err != nil {
-		// 返回是否作为新的失败请求被添加至队列尾部
-		if sp.DoHistory(req, false) {
+	// 错误类型，成功时为空
+	var kind string
+	err := ctx.GetError()
+	if err != nil {
+		var statusCode int
+		if ctx.Response != nil {
+			statusCode = ctx.Response.StatusCode
+		}
+		kind = request.Classify(err, statusCode)
+	}
+	// 解析前检测是否被封禁，如验证码页面
+	blocked := sp.DetectBlock(ctx)
+	if blocked != nil {
+		kind = request.RETRY_BLOCKED
+	}
+	// 反馈下载耗时，用于自适应并发控制及代理IP评分
+	latency := time.Since(start)
+	sp.RequestFeedback(latency, kind)
+	sp.ProxyFeedback(req, latency, kind)
+
+	// 登录失效时重新登录，该请求重新加入队列
+	if sp.SessionExpired(ctx) {
+		if !sp.Relogin(req, session) {
+			if sp.DoFailure(req, request.RETRY_4XX) {
+				cache.PageFailCount()
+			}
+			logs.Log.Error(" *     Fail  [session][%v]: 登录已失效\n", downUrl)
+		}
+		spider.PutContext(ctx)
+		return
+	}
+
+	// 被封禁时按设置应对，不再解析
+	if blocked != nil {
+		if sp.HandleBlock(ctx, blocked, start) {
+			cache.PageFailCount()
+		}
+		spider.PutContext(ctx)
+		return
+	}
+
+	if err != nil {
+		// 返回是否为该请求的首次失败
+		if sp.DoFailure(req, kind) {
 			// 统计失败数
 			cache.PageFailCount()
 		}
 		// 提示错误
 		logs.Log.Error(" *     Fail  [download][%v]: %v\n", downUrl, err)
+		// 关闭错误状态的响应流，以写入WARC存档并释放连接
+		spider.PutContext(ctx)
+		return
+	}
+
+	// 增量抓取时内容未修改，视为成功且无需重新解析
+	if ctx.Response.StatusCode == http.StatusNotModified {
+		sp.Revisited(req, ctx.Response, "")
+		sp.DoHistory(req, true)
+		cache.PageSuccCount()
+		logs.Log.Informational(" *     Not Modified: %v\n", downUrl)
+		spider.PutContext(ctx)
 		return
 	}
 
@@ -187,6 +247,9 @@ func (self *crawler) Process(req *request.Request) {
 		}
 	}
 
+	// 保存增量抓取的校验信息
+	sp.Revisited(req, ctx.Response, ctx.ContentHash())
+
 	// 处理成功请求记录
 	sp.DoHistory(req, true)
 
@@ -200,30 +263,6 @@ func (self *crawler) Process(req *request.Request) {
 	spider.PutContext(ctx)
 }
 
-//TODO: 为方便使用，临时加入代理代码
-/*var proxyHttp *myhttp.HttpSend
-
-func getProxyHost() string {
-	if proxyHttp == nil {
-		proxyHttp = myhttp.NewHttpSend("http://dynamic.goubanjia.com/dynamic/get/d78f737fdc441096922e4ef2391b51bd.html?sep=3")
-	}
-	proxyData, err := proxyHttp.Get(false)
-	if err != nil {
-		logs.Log.Error("获取代理ip失败：" + err.Error())
-		return ""
-	}
-
-	reg := regexp.MustCompile(`\d{1,3}([.]\d{1,3}){3}:\d{2,5}`)
-	res := reg.FindString(string(proxyData))
-	if res != "" {
-		logs.Log.Error("解析代理ip成功：" + "http://" + res)
-		return "http://" + res
-	} else {
-		logs.Log.Error("解析代理ip失败：" + string(proxyData))
-		return ""
-	}
-}
-*/
 // 常用基础方法
 func (self *crawler) sleep() {
 	self.setPauseTime()
@@ -246,6 +285,11 @@ func (self *crawler) FreeOne() {
 	self.Spider.RequestFree()
 }
 
+// DoneOne 通知调度该请求已处理完毕
+func (self *crawler) DoneOne(req *request.Request) {
+	self.Spider.RequestDone(req)
+}
+
 func (self *crawler) SetId(id int) {
 	self.id = id
 }
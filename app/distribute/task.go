@@ -2,17 +2,31 @@ package distribute
 
 // Task 用于分布式分发的任务
 type Task struct {
-	Id             int
-	Spiders        []map[string]string // 蜘蛛规则name字段与keyin字段，规定格式map[string]string{"name":"baidu","keyin":"henry"}
-	ThreadNum      int                 // 全局最大并发量
-	Pausetime      int64               // 暂停时长参考/ms(随机: Pausetime/2 ~ Pausetime*2)
-	OutType        string              // 输出方式
-	DockerCap      int                 // 分段转储容器容量
-	DockerQueueCap int                 // 分段输出池容量，不小于2
-	SuccessInherit bool                // 继承历史成功记录
-	FailureInherit bool                // 继承历史失败记录
-	Limit          int64               // 采集上限，0为不限，若在规则中设置初始值为LIMIT则为自定义限制，否则默认限制请求数
-	ProxySecond    int64               // 代理IP更换的间隔秒数
+	Id              int
+	Spiders         []map[string]string // 蜘蛛规则name字段与keyin字段，规定格式map[string]string{"name":"baidu","keyin":"henry"}
+	ThreadNum       int                 // 全局最大并发量
+	Pausetime       int64               // 暂停时长参考/ms(随机: Pausetime/2 ~ Pausetime*2)
+	OutType         string              // 输出方式
+	DockerCap       int                 // 分段转储容器容量
+	DockerQueueCap  int                 // 分段输出池容量，不小于2
+	SuccessInherit  bool                // 继承历史成功记录
+	FailureInherit  bool                // 继承历史失败记录
+	FrontierInherit bool                // 持久化请求队列，中断后从断点继续抓取
+	Limit           int64               // 采集上限，0为不限，若在规则中设置初始值为LIMIT则为自定义限制，否则默认限制请求数
+	ProxySecond     int64               // 代理IP更换的间隔秒数
+	HostRate        float64             // 每个主机每秒最大请求数，0为不限
+	HostBurst       int                 // 每个主机允许的突发请求数
+	HostConns       int                 // 每个主机最大并发连接数，0为不限
+	HostByIP        bool                // 按解析后的IP而非主机名限速
+	BloomAfter      int                 // 成功记录超过该数量时改用布隆过滤器去重，0为始终精确去重
+	BloomRate       float64             // 布隆过滤器的误判率
+	AutoThrottle    bool                // 根据响应延迟及错误率自动调整并发量与请求间隔
+	AutoMinThread   int                 // 自适应并发的最小并发量
+	AutoMaxThread   int                 // 自适应并发的最大并发量，0为全局并发量
+	AutoMinDelay    int64               // 自适应并发的最小请求间隔（毫秒）
+	AutoMaxDelay    int64               // 自适应并发的最大请求间隔（毫秒），0为不限
+	CacheMode       string              // 响应缓存模式：off、record、replay-only、prefer-cache
+	CacheExpire     int64               // 响应缓存的有效期（秒），0为永不过期
 	// 选填项
 	Keyins string // 自定义输入，后期切分为多个任务的Keyin自定义配置
 }
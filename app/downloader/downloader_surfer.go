@@ -4,11 +4,15 @@ import (
 	"errors"
 	"net/http"
 	"net/http/cookiejar"
+	"strings"
+	"time"
 
 	"github.com/molast/crawler-core/app/downloader/request"
+	"github.com/molast/crawler-core/app/downloader/respcache"
 	"github.com/molast/crawler-core/app/downloader/surfer"
 	"github.com/molast/crawler-core/app/spider"
 	"github.com/molast/crawler-core/config"
+	"github.com/molast/crawler-core/logs"
 )
 
 type Surfer struct {
@@ -24,21 +28,70 @@ var (
 	}
 )
 
+func init() {
+	// 按配置设置DNS解析及缓存
+	var servers []string
+	for _, s := range strings.Split(config.DNS_SERVERS, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			servers = append(servers, s)
+		}
+	}
+	surfer.SetDnsOptions(surfer.DnsOptions{
+		Servers:     servers,
+		TTL:         time.Duration(config.DNS_TTL) * time.Second,
+		NegativeTTL: time.Duration(config.DNS_NEGATIVE_TTL) * time.Second,
+	})
+	// 按配置设置共享连接池
+	surfer.SetTransportOptions(surfer.TransportOptions{
+		MaxIdleConns:        config.HTTP_MAX_IDLE_CONNS,
+		MaxIdleConnsPerHost: config.HTTP_MAX_IDLE_PER_HOST,
+		MaxConnsPerHost:     config.HTTP_MAX_CONNS_PER_HOST,
+		IdleConnTimeout:     time.Duration(config.HTTP_IDLE_SECOND) * time.Second,
+		DisableKeepAlives:   config.HTTP_DISABLE_KEEPALIVES,
+		DisableHTTP2:        config.HTTP_DISABLE_HTTP2,
+	})
+}
+
 func (self *Surfer) Download(sp *spider.Spider, cReq *request.Request) *spider.Context {
 	ctx := spider.GetContext(sp, cReq)
+	if jar := sp.GetCookieJar(); jar != nil {
+		// 各Spider实例使用独立的cookie容器
+		cReq.SetCookieJar(jar)
+	}
 
 	var resp *http.Response
 	var err error
 
-	switch cReq.GetDownloaderID() {
-	case request.SURF_ID:
-		resp, err = self.surf.Download(cReq)
+	if replayer := sp.GetWarcReplayer(); replayer != nil {
+		// 以WARC存档中的响应代替实际下载
+		resp, err = replayer.Download(cReq)
+	} else {
+		// 实际下载的原始响应（解码压缩编码前）在读取时同步写入WARC存档，读取自响应缓存的不存档
+		var hook func(*http.Response)
+		if w := sp.GetWarcWriter(); w != nil {
+			hook = func(resp *http.Response) {
+				if _, e := w.WriteExchange(cReq, resp); e != nil {
+					logs.Log.Error(" *     Fail  [WARC存档][%v]: %v\n", cReq.GetUrl(), e)
+				}
+			}
+		}
+		cReq.SetRawHook(hook)
+
+		switch cReq.GetDownloaderID() {
+		case request.SURF_ID:
+			resp, err = respcache.Download(cReq, self.surf)
 
-	case request.PHANTOM_ID:
-		resp, err = self.phantom.Download(cReq)
+		case request.PHANTOM_ID:
+			resp, err = respcache.Download(cReq, self.phantom)
+		}
 	}
 
-	if resp.StatusCode >= 400 {
+	if resp == nil {
+		// 未获得响应（如仅从缓存读取且缓存中不存在）时，不构造响应，由错误说明原因
+		if err == nil {
+			err = errors.New("未获得响应")
+		}
+	} else if resp.StatusCode >= 400 {
 		err = errors.New("响应状态 " + resp.Status)
 	}
 
@@ -0,0 +1,136 @@
+package request
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// DefaultStripParams 默认删除的跟踪类查询参数，以*结尾时按前缀匹配
+var DefaultStripParams = []string{
+	"utm_*",
+	"gclid",
+	"fbclid",
+	"msclkid",
+	"yclid",
+	"mc_cid",
+	"mc_eid",
+	"_ga",
+}
+
+type (
+	// Canonicalizer Url规范化规则，
+	// 统一scheme及host的大小写，去除默认端口、片段及跟踪参数，并将查询参数按名称排序
+	Canonicalizer struct {
+		StripParams  []string // 删除的查询参数名，以*结尾时按前缀匹配
+		KeepFragment bool     // 是否保留#之后的片段
+	}
+	// Fingerprint 请求指纹（去重依据）的生成规则，在Spider的Fingerprint统一设置
+	Fingerprint struct {
+		Canonicalizer *Canonicalizer // Url规范化规则，为nil时不做规范化
+		Body          bool           // 是否包含请求体PostData
+		Headers       []string       // 参与计算的请求头名称
+	}
+)
+
+// NewCanonicalizer 返回删除默认跟踪参数的Url规范化规则
+func NewCanonicalizer() *Canonicalizer {
+	return &Canonicalizer{
+		StripParams: DefaultStripParams,
+	}
+}
+
+// NewFingerprint 返回默认的请求指纹规则，对Url进行规范化并包含请求体
+func NewFingerprint() *Fingerprint {
+	return &Fingerprint{
+		Canonicalizer: NewCanonicalizer(),
+		Body:          true,
+	}
+}
+
+// Canonicalize 返回规范化后的Url，解析失败时返回原Url
+func (self *Canonicalizer) Canonicalize(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return rawurl
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if u.Scheme == "http" && port == "80" || u.Scheme == "https" && port == "443" {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+	if u.Path == "" && u.Opaque == "" {
+		u.Path = "/"
+	}
+	if !self.KeepFragment {
+		u.Fragment = ""
+		u.RawFragment = ""
+	}
+	if u.RawQuery != "" {
+		query, err := url.ParseQuery(u.RawQuery)
+		if err == nil {
+			for key := range query {
+				if self.strip(key) {
+					delete(query, key)
+				}
+			}
+			// Encode按参数名排序
+			u.RawQuery = query.Encode()
+		}
+	}
+	u.ForceQuery = false
+	return u.String()
+}
+
+// 是否为需要删除的查询参数
+func (self *Canonicalizer) strip(key string) bool {
+	key = strings.ToLower(key)
+	for _, p := range self.StripParams {
+		p = strings.ToLower(p)
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(key, p[:len(p)-1]) {
+				return true
+			}
+		} else if key == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Sum 计算请求的指纹
+func (self *Fingerprint) Sum(req *Request) string {
+	u := req.UrlAlias
+	if u == "" {
+		u = req.Url
+		if self.Canonicalizer != nil {
+			u = self.Canonicalizer.Canonicalize(u)
+		}
+	}
+	parts := []string{req.Spider, req.Rule, u, req.Method}
+	if self.Body {
+		parts = append(parts, req.PostData)
+	}
+	if len(self.Headers) > 0 {
+		names := make([]string, len(self.Headers))
+		for i, name := range self.Headers {
+			names[i] = http.CanonicalHeaderKey(name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			parts = append(parts, name+":"+strings.Join(req.Header[name], ","))
+		}
+	}
+	block := md5.Sum([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(block[:])
+}
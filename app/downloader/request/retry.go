@@ -0,0 +1,114 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+// 失败请求的错误类型
+const (
+	RETRY_DNS     = "dns"     // 域名解析失败
+	RETRY_TIMEOUT = "timeout" // 连接或下载超时
+	RETRY_5XX     = "5xx"     // 服务端错误
+	RETRY_429     = "429"     // 请求过于频繁
+	RETRY_4XX     = "4xx"     // 除429外的客户端错误
+	RETRY_PANIC   = "panic"   // 解析过程崩溃
+	RETRY_BLOCKED = "blocked" // 被目标网站封禁或要求验证码
+	RETRY_OTHER   = "other"   // 其他错误
+)
+
+type (
+	// RetryPolicy 失败请求的重试策略，按错误类型决定重试次数与等待时长
+	RetryPolicy struct {
+		Default RetryRule            // 未单独设置的错误类型所用的规则
+		Rules   map[string]RetryRule // [错误类型]重试规则
+	}
+	// RetryRule 重试规则，第n次重试前等待 Backoff*Multiplier^(n-1)，不超过MaxBackoff
+	RetryRule struct {
+		Attempts   int           // 最大尝试次数（含首次），<=1时不再重试，直接记为失败
+		Backoff    time.Duration // 首次重试前的等待时长
+		MaxBackoff time.Duration // 等待时长上限，<=0为不限
+		Multiplier float64       // 每次重试等待时长的增长倍数，<1时按1计算
+		Jitter     float64       // 随机抖动比例(0~1)，实际等待时长在 delay*(1±Jitter) 之间
+	}
+)
+
+// NewRetryPolicy 返回默认的重试策略
+func NewRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		Default: RetryRule{Attempts: 2, Backoff: 5 * time.Second, Multiplier: 2, Jitter: 0.2},
+		Rules: map[string]RetryRule{
+			RETRY_DNS:     {Attempts: 2, Backoff: 30 * time.Second},
+			RETRY_TIMEOUT: {Attempts: 3, Backoff: 5 * time.Second, MaxBackoff: time.Minute, Multiplier: 2, Jitter: 0.2},
+			RETRY_5XX:     {Attempts: 3, Backoff: 10 * time.Second, MaxBackoff: 2 * time.Minute, Multiplier: 2, Jitter: 0.2},
+			RETRY_429:     {Attempts: 5, Backoff: 30 * time.Second, MaxBackoff: 10 * time.Minute, Multiplier: 2, Jitter: 0.3},
+			RETRY_4XX:     {Attempts: 1},
+			RETRY_PANIC:   {Attempts: 1},
+			RETRY_BLOCKED: {Attempts: 3, Backoff: time.Minute, MaxBackoff: 10 * time.Minute, Multiplier: 2, Jitter: 0.3},
+		},
+	}
+}
+
+// Rule 返回指定错误类型的重试规则
+func (self *RetryPolicy) Rule(kind string) RetryRule {
+	if rule, ok := self.Rules[kind]; ok {
+		return rule
+	}
+	return self.Default
+}
+
+// Delay 返回第attempt次重试前的等待时长，attempt从1开始
+func (self RetryRule) Delay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	multiplier := self.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	d := float64(self.Backoff) * math.Pow(multiplier, float64(attempt-1))
+	if self.MaxBackoff > 0 && d > float64(self.MaxBackoff) {
+		d = float64(self.MaxBackoff)
+	}
+	if self.Jitter > 0 {
+		jitter := math.Min(self.Jitter, 1)
+		d *= 1 + jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(d)
+}
+
+// Classify 根据下载错误及响应状态码判断错误类型
+func Classify(err error, statusCode int) string {
+	switch {
+	case statusCode == 429:
+		return RETRY_429
+	case statusCode >= 500:
+		return RETRY_5XX
+	case statusCode >= 400:
+		return RETRY_4XX
+	case err == nil:
+		return RETRY_OTHER
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return RETRY_DNS
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() || errors.Is(err, context.DeadlineExceeded) {
+		return RETRY_TIMEOUT
+	}
+	// 部分下载内核仅返回错误文本
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "no such host"):
+		return RETRY_DNS
+	case strings.Contains(msg, "timeout"), strings.Contains(msg, "timed out"):
+		return RETRY_TIMEOUT
+	}
+	return RETRY_OTHER
+}
@@ -0,0 +1,296 @@
+package respcache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/molast/crawler-core/app/downloader/request"
+	"github.com/molast/crawler-core/app/downloader/surfer"
+	"github.com/molast/crawler-core/common/util"
+	"github.com/molast/crawler-core/config"
+	"github.com/molast/crawler-core/logs"
+	"github.com/molast/crawler-core/runtime/cache"
+)
+
+// 响应缓存模式
+const (
+	OFF    = "off"          // 不使用缓存
+	RECORD = "record"       // 始终下载，并将响应写入缓存
+	REPLAY = "replay-only"  // 仅从缓存读取，缓存不存在时返回错误，不访问网络
+	PREFER = "prefer-cache" // 优先读取缓存，缓存不存在或已过期时下载并写入缓存
+)
+
+// 缓存文件扩展名
+const FILE_EXT = ".resp"
+
+// ErrNotCached replay-only模式下请求的响应不在缓存中
+var ErrNotCached = errors.New("响应缓存中不存在该请求")
+
+// Cache 以请求指纹为键保存于磁盘的响应缓存，用于开发规则时离线重放已抓取的页面
+type Cache struct {
+	dir    string
+	mode   string
+	expire time.Duration
+	sync.RWMutex
+}
+
+var global = New(config.RESPCACHE_DIR, OFF, 0)
+
+// Init 按任务运行时配置重置全局响应缓存
+func Init() {
+	mode := strings.ToLower(strings.TrimSpace(cache.Task.CacheMode))
+	switch mode {
+	case RECORD, REPLAY, PREFER:
+	default:
+		mode = OFF
+	}
+	expire := time.Duration(cache.Task.CacheExpire) * time.Second
+	global.Reset(mode, expire)
+	if mode != OFF {
+		logs.Log.Informational(" *     响应缓存：模式 %v，有效期 %v 秒（0为永不过期）\n", mode, cache.Task.CacheExpire)
+	}
+}
+
+// Download 经由全局响应缓存下载
+func Download(req *request.Request, download surfer.Surfer) (*http.Response, error) {
+	return global.Download(req, download)
+}
+
+// New 创建响应缓存，expire<=0时永不过期
+func New(dir, mode string, expire time.Duration) *Cache {
+	return &Cache{
+		dir:    dir,
+		mode:   mode,
+		expire: expire,
+	}
+}
+
+// Reset 修改缓存模式及有效期
+func (self *Cache) Reset(mode string, expire time.Duration) {
+	self.Lock()
+	defer self.Unlock()
+	self.mode = mode
+	self.expire = expire
+}
+
+// Mode 返回缓存模式
+func (self *Cache) Mode() string {
+	self.RLock()
+	defer self.RUnlock()
+	return self.mode
+}
+
+// Download 按缓存模式读取缓存或使用download下载
+func (self *Cache) Download(req *request.Request, download surfer.Surfer) (*http.Response, error) {
+	self.RLock()
+	mode, expire := self.mode, self.expire
+	self.RUnlock()
+
+	switch mode {
+	case REPLAY:
+		resp, err := self.Get(req, 0)
+		if err != nil {
+			return nil, err
+		}
+		if resp == nil {
+			return nil, ErrNotCached
+		}
+		return resp, nil
+
+	case PREFER:
+		if resp, err := self.Get(req, expire); err == nil && resp != nil {
+			return resp, nil
+		}
+		fallthrough
+
+	case RECORD:
+		resp, err := download.Download(req)
+		if err != nil || resp == nil {
+			return resp, err
+		}
+		return self.Put(req, resp)
+	}
+
+	return download.Download(req)
+}
+
+// Get 读取请求的缓存响应，不存在或已过期时返回nil，expire<=0时不检查过期，
+// 响应内容直接读取自缓存文件，关闭响应时关闭文件
+func (self *Cache) Get(req *request.Request, expire time.Duration) (*http.Response, error) {
+	fileName := self.fileName(req)
+	info, err := os.Stat(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if expire > 0 && time.Since(info.ModTime()) > expire {
+		return nil, nil
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	// 首行为最终的Url（可能经过重定向），其后为HTTP响应报文，响应内容读取至文件末尾
+	r := bufio.NewReader(f)
+	u, err := r.ReadString('\n')
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	httpReq, err := http.NewRequest(req.GetMethod(), strings.TrimSpace(u), nil)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	httpReq.Header = req.GetHeader()
+	resp, err := http.ReadResponse(r, httpReq)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	resp.Body = &fileBody{ReadCloser: resp.Body, file: f}
+	return resp, nil
+}
+
+// Put 写入响应缓存：先写入最终的Url及响应头，响应内容在读取时同步写入，读取完毕后缓存生效，
+// 未读取完毕即关闭或读取出错时放弃缓存；状态码不为2xx或3xx、以及304的响应不写入缓存
+func (self *Cache) Put(req *request.Request, resp *http.Response) (*http.Response, error) {
+	if resp.StatusCode >= 400 || resp.StatusCode < 200 || resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+	fileName := self.fileName(req)
+	f, err := self.create(fileName)
+	if err != nil {
+		logs.Log.Error(" *     Fail  [写入响应缓存][%v]: %v\n", req.GetUrl(), err)
+		return resp, nil
+	}
+	w := bufio.NewWriter(f)
+	if err = writeHead(w, req, resp); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		logs.Log.Error(" *     Fail  [写入响应缓存][%v]: %v\n", req.GetUrl(), err)
+		return resp, nil
+	}
+	resp.Body = &teeBody{body: resp.Body, file: f, w: w, fileName: fileName, url: req.GetUrl()}
+	return resp, nil
+}
+
+// 在缓存文件所在目录创建临时文件，写入完毕后改名，避免并发读取到不完整的缓存
+func (self *Cache) create(fileName string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(fileName), 0777); err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(filepath.Dir(fileName), "*.tmp")
+}
+
+// 写入最终的Url、状态行及响应头，不含Content-Length及Transfer-Encoding，读取时以文件末尾为内容的结束
+func writeHead(w io.Writer, req *request.Request, resp *http.Response) error {
+	u := req.GetUrl()
+	if resp.Request != nil && resp.Request.URL != nil {
+		u = resp.Request.URL.String()
+	}
+	major, minor := resp.ProtoMajor, resp.ProtoMinor
+	if major == 0 {
+		major, minor = 1, 1
+	}
+	text := strings.TrimSpace(strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode)))
+	if text == "" {
+		text = http.StatusText(resp.StatusCode)
+	}
+	if _, err := fmt.Fprintf(w, "%s\nHTTP/%d.%d %03d %s\r\n", u, major, minor, resp.StatusCode, text); err != nil {
+		return err
+	}
+	header := resp.Header.Clone()
+	header.Del("Content-Length")
+	header.Del("Transfer-Encoding")
+	if err := header.Write(w); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\r\n")
+	return err
+}
+
+// 读取响应内容时同步写入缓存文件
+type teeBody struct {
+	body     io.ReadCloser
+	file     *os.File
+	w        *bufio.Writer
+	fileName string // 缓存文件名，读取完毕后由临时文件改名
+	url      string
+	err      error // 写入缓存文件的错误，出错后不再写入
+	done     bool
+}
+
+func (self *teeBody) Read(p []byte) (int, error) {
+	n, err := self.body.Read(p)
+	if n > 0 && self.err == nil && !self.done {
+		_, self.err = self.w.Write(p[:n])
+	}
+	switch {
+	case err == io.EOF:
+		self.finish(true)
+	case err != nil:
+		self.finish(false)
+	}
+	return n, err
+}
+
+func (self *teeBody) Close() error {
+	self.finish(false)
+	return self.body.Close()
+}
+
+// 关闭缓存文件，complete为true且写入无误时生效，否则删除
+func (self *teeBody) finish(complete bool) {
+	if self.done {
+		return
+	}
+	self.done = true
+	err := self.err
+	if err == nil {
+		err = self.w.Flush()
+	}
+	if e := self.file.Close(); err == nil {
+		err = e
+	}
+	if complete && err == nil {
+		err = os.Rename(self.file.Name(), self.fileName)
+	} else {
+		os.Remove(self.file.Name())
+	}
+	if complete && err != nil {
+		logs.Log.Error(" *     Fail  [写入响应缓存][%v]: %v\n", self.url, err)
+	}
+}
+
+// 读取自缓存文件的响应内容，关闭时关闭文件
+type fileBody struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (self *fileBody) Close() error {
+	err := self.ReadCloser.Close()
+	if e := self.file.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// 缓存文件路径，按指纹的前两位分目录存放
+func (self *Cache) fileName(req *request.Request) string {
+	key := req.Unique()
+	return filepath.Join(self.dir, util.FileNameReplace(req.GetSpiderName()), key[:2], key+FILE_EXT)
+}
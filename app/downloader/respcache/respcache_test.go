@@ -0,0 +1,87 @@
+package respcache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/molast/crawler-core/app/downloader/request"
+	"github.com/molast/crawler-core/app/downloader/surfer"
+)
+
+func TestCacheModes(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("X-Test", "1")
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	s := surfer.New()
+	c := New(t.TempDir(), OFF, 0)
+	get := func() (string, error) {
+		req := &request.Request{Spider: "test", Url: srv.URL + "/page", Rule: "test"}
+		req.Prepare()
+		resp, err := c.Download(req, s)
+		if err != nil {
+			return "", err
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.Header.Get("X-Test") != "1" || resp.Request == nil {
+			t.Fatalf("header = %v", resp.Header)
+		}
+		return string(b), nil
+	}
+
+	c.Reset(REPLAY, 0)
+	if _, err := get(); err != ErrNotCached {
+		t.Fatalf("replay miss: err = %v", err)
+	}
+
+	// 未读取完毕的响应不写入缓存
+	c.Reset(RECORD, 0)
+	req := &request.Request{Spider: "test", Url: srv.URL + "/page", Rule: "test"}
+	req.Prepare()
+	resp, err := c.Download(req, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Read(make([]byte, 2))
+	resp.Body.Close()
+	c.Reset(REPLAY, 0)
+	if _, err := get(); err != ErrNotCached {
+		t.Fatalf("partial record: err = %v", err)
+	}
+
+	c.Reset(RECORD, 0)
+	if b, err := get(); err != nil || b != "hello" {
+		t.Fatalf("record: %q %v", b, err)
+	}
+
+	c.Reset(REPLAY, 0)
+	if b, err := get(); err != nil || b != "hello" {
+		t.Fatalf("replay: %q %v", b, err)
+	}
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Fatalf("hits after replay = %d", n)
+	}
+
+	c.Reset(PREFER, time.Hour)
+	get()
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Fatalf("hits after prefer-cache = %d", n)
+	}
+
+	// 过期后重新下载
+	c.Reset(PREFER, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	get()
+	if n := atomic.LoadInt32(&hits); n != 3 {
+		t.Fatalf("hits after expire = %d", n)
+	}
+}
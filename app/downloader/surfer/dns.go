@@ -0,0 +1,235 @@
+package surfer
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// DnsOptions DNS解析及缓存的配置
+	DnsOptions struct {
+		Servers     []string      // 上游DNS服务器，如"8.8.8.8:53"，依次尝试，为空时使用系统解析器
+		TTL         time.Duration // 缓存时长的上限，系统解析器无法获得TTL时即为缓存时长，0为不缓存
+		NegativeTTL time.Duration // 域名不存在（NXDOMAIN）时的缓存时长，0为不缓存
+		Timeout     time.Duration // 每次向上游DNS服务器查询的超时时长，0为5秒
+	}
+	// Resolver 域名解析器
+	Resolver interface {
+		// Lookup 返回域名的所有A及AAAA记录，及记录的TTL，无法获得TTL时返回NoTTL，
+		// 域名不存在时返回IsNotFound为true的*net.DNSError
+		Lookup(ctx context.Context, host string) (ips []net.IP, ttl time.Duration, err error)
+	}
+	// DnsCache 按TTL缓存的DNS解析结果，同一域名的多个IP轮流使用
+	DnsCache struct {
+		resolver    Resolver
+		ttl         time.Duration
+		negativeTTL time.Duration
+		entries     map[string]*dnsEntry  // [域名]解析结果
+		inflight    map[string]*dnsLookup // [域名]正在进行的解析
+		stat        DnsStat
+		sync.Mutex
+	}
+	// DnsStat DNS缓存的统计信息
+	DnsStat struct {
+		Entries      int   // 缓存的域名数
+		Hits         int64 // 命中缓存的次数
+		NegativeHits int64 // 命中域名不存在的缓存的次数
+		Misses       int64 // 未命中缓存的次数
+		Lookups      int64 // 实际解析的次数（并发的相同解析只计一次）
+		Errors       int64 // 解析失败的次数，含域名不存在
+		Evictions    int64 // 因连接失败而移除缓存的次数
+	}
+	dnsEntry struct {
+		ips     []net.IP
+		err     error // 域名不存在
+		expires time.Time
+		next    uint32 // 下次优先使用的IP索引
+	}
+	dnsLookup struct {
+		ips  []net.IP
+		err  error
+		done chan struct{}
+	}
+	// 系统解析器
+	systemResolver struct{}
+)
+
+// NoTTL 解析器无法获得记录的TTL（如系统解析器），与TTL为0（不应缓存）相区别
+const NoTTL time.Duration = -1
+
+// DefaultDnsOptions 默认的DNS配置
+var DefaultDnsOptions = DnsOptions{
+	TTL:         5 * time.Minute,
+	NegativeTTL: 30 * time.Second,
+}
+
+var (
+	dnsCache = NewDnsCache(DefaultDnsOptions)
+	dnsLock  sync.RWMutex
+)
+
+// SetDnsOptions 设置DNS解析及缓存，并清空已有的缓存
+func SetDnsOptions(opts DnsOptions) {
+	cache := NewDnsCache(opts)
+	dnsLock.Lock()
+	dnsCache = cache
+	dnsLock.Unlock()
+}
+
+// GetDnsStat 返回DNS缓存的统计信息
+func GetDnsStat() DnsStat {
+	return currentDnsCache().Stat()
+}
+
+// ResolveHost 经全局DNS缓存解析域名，返回的IP已按轮流使用的顺序排列
+func ResolveHost(ctx context.Context, host string) ([]net.IP, error) {
+	return currentDnsCache().Resolve(ctx, host)
+}
+
+func currentDnsCache() *DnsCache {
+	dnsLock.RLock()
+	defer dnsLock.RUnlock()
+	return dnsCache
+}
+
+// NewDnsCache 按配置创建DNS缓存
+func NewDnsCache(opts DnsOptions) *DnsCache {
+	var resolver Resolver = systemResolver{}
+	if len(opts.Servers) > 0 {
+		resolver = NewServerResolver(opts.Timeout, opts.Servers...)
+	}
+	return NewDnsCacheWith(resolver, opts.TTL, opts.NegativeTTL)
+}
+
+// NewDnsCacheWith 使用指定的解析器创建DNS缓存
+func NewDnsCacheWith(resolver Resolver, ttl, negativeTTL time.Duration) *DnsCache {
+	return &DnsCache{
+		resolver:    resolver,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		entries:     make(map[string]*dnsEntry),
+		inflight:    make(map[string]*dnsLookup),
+	}
+}
+
+// Resolve 返回域名的IP列表，已按轮流使用的顺序排列，优先使用缓存
+func (d *DnsCache) Resolve(ctx context.Context, host string) ([]net.IP, error) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	now := time.Now()
+
+	d.Lock()
+	if e, ok := d.entries[host]; ok {
+		if now.Before(e.expires) {
+			if e.err != nil {
+				d.stat.NegativeHits++
+				d.Unlock()
+				return nil, e.err
+			}
+			d.stat.Hits++
+			ips := rotate(e.ips, e.next)
+			e.next++
+			d.Unlock()
+			return ips, nil
+		}
+		delete(d.entries, host)
+	}
+	d.stat.Misses++
+	l, ok := d.inflight[host]
+	if !ok {
+		l = &dnsLookup{done: make(chan struct{})}
+		d.inflight[host] = l
+		d.stat.Lookups++
+	}
+	d.Unlock()
+
+	if ok {
+		// 等待并发的相同解析
+		select {
+		case <-l.done:
+			return rotate(l.ips, rand.Uint32()), l.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	ips, ttl, err := d.resolver.Lookup(ctx, host)
+	if err == nil && len(ips) == 0 {
+		err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	l.ips, l.err = ips, err
+
+	d.Lock()
+	delete(d.inflight, host)
+	if err != nil {
+		d.stat.Errors++
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound && d.negativeTTL > 0 {
+			d.entries[host] = &dnsEntry{err: err, expires: time.Now().Add(d.negativeTTL)}
+		}
+	} else if ttl = d.cacheTTL(ttl); ttl > 0 {
+		d.entries[host] = &dnsEntry{ips: ips, expires: time.Now().Add(ttl), next: 1}
+	}
+	d.Unlock()
+	close(l.done)
+	return ips, err
+}
+
+// Del 移除域名的缓存，如连接其所有IP均失败时
+func (d *DnsCache) Del(host string) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	d.Lock()
+	defer d.Unlock()
+	if _, ok := d.entries[host]; ok {
+		delete(d.entries, host)
+		d.stat.Evictions++
+	}
+}
+
+// Stat 返回统计信息
+func (d *DnsCache) Stat() DnsStat {
+	d.Lock()
+	defer d.Unlock()
+	stat := d.stat
+	now := time.Now()
+	for _, e := range d.entries {
+		if now.Before(e.expires) {
+			stat.Entries++
+		}
+	}
+	return stat
+}
+
+// 记录的TTL不超过配置的上限，无法获得TTL时使用上限，记录的TTL为0时不缓存
+func (d *DnsCache) cacheTTL(ttl time.Duration) time.Duration {
+	if ttl < 0 || ttl > d.ttl {
+		return d.ttl
+	}
+	return ttl
+}
+
+// 返回从第n%len(ips)个开始的IP列表
+func rotate(ips []net.IP, n uint32) []net.IP {
+	if len(ips) < 2 {
+		return ips
+	}
+	i := int(n % uint32(len(ips)))
+	return append(append(make([]net.IP, 0, len(ips)), ips[i:]...), ips[:i]...)
+}
+
+// Lookup 使用系统解析器，无法获得TTL
+func (systemResolver) Lookup(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, 0, err
+	}
+	ips := make([]net.IP, len(addrs))
+	for i, a := range addrs {
+		ips[i] = a.IP
+	}
+	return ips, NoTTL, nil
+}
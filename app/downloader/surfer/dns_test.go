@@ -0,0 +1,138 @@
+package surfer_test
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/molast/crawler-core/app/downloader/surfer"
+)
+
+// 本地DNS服务器：multi.test.有两条A记录，其余域名均不存在
+func startDnsServer(t *testing.T, ttl uint32) (string, *int32) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	var queries int32
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var req dnsmessage.Message
+			if req.Unpack(buf[:n]) != nil || len(req.Questions) != 1 {
+				continue
+			}
+			atomic.AddInt32(&queries, 1)
+			q := req.Questions[0]
+			resp := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: req.ID, Response: true, RCode: dnsmessage.RCodeSuccess},
+				Questions: req.Questions,
+			}
+			switch {
+			case q.Name.String() != "multi.test.":
+				resp.RCode = dnsmessage.RCodeNameError
+			case q.Type == dnsmessage.TypeA:
+				for _, ip := range [][4]byte{{10, 0, 0, 1}, {10, 0, 0, 2}} {
+					resp.Answers = append(resp.Answers, dnsmessage.Resource{
+						Header: dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: q.Class, TTL: ttl},
+						Body:   &dnsmessage.AResource{A: ip},
+					})
+				}
+			}
+			b, _ := resp.Pack()
+			pc.WriteTo(b, addr)
+		}
+	}()
+	return pc.LocalAddr().String(), &queries
+}
+
+func TestDnsCache(t *testing.T) {
+	server, queries := startDnsServer(t, 1)
+	// 首个服务器不可用，应切换到下一个
+	dead, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadAddr := dead.LocalAddr().String()
+	dead.Close()
+
+	cache := surfer.NewDnsCache(surfer.DnsOptions{
+		Servers:     []string{deadAddr, server},
+		TTL:         time.Minute,
+		NegativeTTL: time.Minute,
+		Timeout:     500 * time.Millisecond,
+	})
+	ctx := context.Background()
+
+	first, err := cache.Resolve(ctx, "multi.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 2 {
+		t.Fatalf("ips = %v", first)
+	}
+	second, err := cache.Resolve(ctx, "MULTI.test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first[0].Equal(second[1]) || !first[1].Equal(second[0]) {
+		t.Fatalf("not rotated: %v %v", first, second)
+	}
+	if n := atomic.LoadInt32(queries); n != 2 {
+		t.Fatalf("queries = %d, want 2 (A and AAAA once)", n)
+	}
+
+	// 域名不存在时缓存否定结果
+	for i := 0; i < 2; i++ {
+		_, err := cache.Resolve(ctx, "missing.test")
+		dnsErr, ok := err.(*net.DNSError)
+		if !ok || !dnsErr.IsNotFound {
+			t.Fatalf("err = %v", err)
+		}
+	}
+
+	// 记录的TTL为1秒，过期后重新查询
+	time.Sleep(1100 * time.Millisecond)
+	if _, err := cache.Resolve(ctx, "multi.test"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(queries); n != 6 {
+		t.Fatalf("queries = %d, want 6", n)
+	}
+
+	cache.Del("multi.test")
+	stat := cache.Stat()
+	want := surfer.DnsStat{Entries: 1, Hits: 1, NegativeHits: 1, Misses: 3, Lookups: 3, Errors: 1, Evictions: 1}
+	if stat != want {
+		t.Fatalf("stat = %+v, want %+v", stat, want)
+	}
+}
+
+func TestDnsCacheZeroTTL(t *testing.T) {
+	server, queries := startDnsServer(t, 0)
+	cache := surfer.NewDnsCache(surfer.DnsOptions{
+		Servers: []string{server},
+		TTL:     time.Minute,
+	})
+	// 记录的TTL为0时不缓存，每次均重新查询
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Resolve(context.Background(), "multi.test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(queries); n != 4 {
+		t.Fatalf("queries = %d, want 4", n)
+	}
+	if stat := cache.Stat(); stat.Entries != 0 || stat.Hits != 0 {
+		t.Fatalf("stat = %+v", stat)
+	}
+}
@@ -0,0 +1,113 @@
+package surfer
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// 请求时声明支持的压缩编码
+const acceptEncoding = "gzip, deflate, br, zstd"
+
+// 解码后的响应内容，关闭时依次关闭各层解码器及原始响应流，
+// 读取完毕时将解码后的字节数写入响应的ContentLength
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+	resp    *http.Response
+	n       int64
+}
+
+func (self *decodedBody) Read(p []byte) (int, error) {
+	n, err := self.Reader.Read(p)
+	self.n += int64(n)
+	if err == io.EOF {
+		self.resp.ContentLength = self.n
+	}
+	return n, err
+}
+
+func (self *decodedBody) Close() error {
+	var err error
+	for i := len(self.closers) - 1; i >= 0; i-- {
+		if e := self.closers[i].Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// DecodeBody 按Content-Encoding解码响应内容，支持多重编码（如"gzip, br"），
+// Surf下载时自动调用，亦用于其他来源的原始响应（如WARC存档）；
+// 解码后删除Content-Encoding及Content-Length，读取前ContentLength为-1（同net/http的自动解压），
+// 读取完毕后为解码后的字节数，与原响应头的Content-Length（压缩后的字节数）不同。
+// 含不支持的编码时保持原样。
+func DecodeBody(resp *http.Response) error {
+	var encodings []string
+	for _, v := range resp.Header["Content-Encoding"] {
+		for _, enc := range strings.Split(v, ",") {
+			enc = strings.ToLower(strings.TrimSpace(enc))
+			switch enc {
+			case "", "identity":
+			case "gzip", "x-gzip", "deflate", "zlib", "br", "zstd":
+				encodings = append(encodings, enc)
+			default:
+				return nil
+			}
+		}
+	}
+	if len(encodings) == 0 {
+		return nil
+	}
+
+	body := &decodedBody{Reader: resp.Body, closers: []io.Closer{resp.Body}, resp: resp}
+	// 按编码顺序的逆序解码
+	for i := len(encodings) - 1; i >= 0; i-- {
+		r, err := newDecoder(encodings[i], body.Reader)
+		if err != nil {
+			return err
+		}
+		if c, ok := r.(io.Closer); ok {
+			body.closers = append(body.closers, c)
+		}
+		body.Reader = r
+	}
+	resp.Body = body
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+func newDecoder(encoding string, r io.Reader) (io.Reader, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(r)
+	case "deflate":
+		// 多数服务器发送带zlib头的deflate数据，少数发送原始deflate数据
+		br := bufio.NewReader(r)
+		if b, err := br.Peek(2); err == nil && b[0]&0x0f == 8 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0 {
+			return zlib.NewReader(br)
+		}
+		return flate.NewReader(br), nil
+	case "zlib":
+		return zlib.NewReader(r)
+	case "br":
+		return brotli.NewReader(r), nil
+	case "zstd":
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}
+	return r, nil
+}
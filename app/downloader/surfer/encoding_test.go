@@ -0,0 +1,84 @@
+package surfer_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/molast/crawler-core/app/downloader/request"
+	"github.com/molast/crawler-core/app/downloader/surfer"
+)
+
+const encodingText = "<html><body>压缩内容 compressed content</body></html>"
+
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		var err error
+		if w, err = zstd.NewWriter(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestContentEncoding(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "br") {
+			t.Errorf("Accept-Encoding = %q", r.Header.Get("Accept-Encoding"))
+		}
+		encoding := r.URL.Query().Get("e")
+		data := []byte(encodingText)
+		for _, enc := range strings.Split(encoding, ",") {
+			if enc = strings.TrimSpace(enc); enc != "" {
+				data = compress(t, enc, data)
+			}
+		}
+		w.Header().Set("Content-Encoding", encoding)
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	for _, encoding := range []string{"gzip", "deflate", "zlib", "br", "zstd", "gzip, br", "zstd,gzip"} {
+		req := &request.Request{Url: srv.URL + "/?e=" + strings.Replace(encoding, " ", "", -1), Rule: "test"}
+		req.Prepare()
+		resp, err := surfer.New().Download(req)
+		if err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+		if resp.Header.Get("Content-Encoding") != "" || resp.Header.Get("Content-Length") != "" || resp.ContentLength != -1 {
+			t.Errorf("%s: encoding headers not cleared", encoding)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(b) != encodingText {
+			t.Errorf("%s: body = %q", encoding, b)
+		}
+		// 读取完毕后为解码后的字节数
+		if resp.ContentLength != int64(len(encodingText)) {
+			t.Errorf("%s: ContentLength = %d", encoding, resp.ContentLength)
+		}
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -35,6 +36,8 @@ type Param struct {
 	body          io.Reader
 	header        http.Header
 	enableCookie  bool
+	jar           http.CookieJar       // 请求自带的cookie容器
+	rawHook       func(*http.Response) // 原始响应的处理函数
 	dialTimeout   time.Duration
 	connTimeout   time.Duration
 	tryTimes      int
@@ -91,6 +94,12 @@ func NewParam(req Request) (param *Param, err error) {
 	}
 
 	param.enableCookie = req.GetEnableCookie()
+	if r, ok := req.(CookieJarRequest); ok {
+		param.jar = r.GetCookieJar()
+	}
+	if r, ok := req.(RawResponseRequest); ok {
+		param.rawHook = r.GetRawHook()
+	}
 
 	if len(param.header.Get("User-Agent")) == 0 {
 		if param.enableCookie {
@@ -102,6 +111,13 @@ func NewParam(req Request) (param *Param, err error) {
 		}
 	}
 
+	// 续传时须禁用压缩编码，以保证字节偏移一致；续传头不写入原请求
+	if r, ok := req.(ResumeRequest); ok && r.GetResumeAt() > 0 {
+		param.header = param.header.Clone()
+		param.header.Set("Range", "bytes="+strconv.FormatInt(r.GetResumeAt(), 10)+"-")
+		param.header.Set("Accept-Encoding", "identity")
+	}
+
 	param.dialTimeout = req.GetDialTimeout()
 	if param.dialTimeout < 0 {
 		param.dialTimeout = 0
@@ -149,3 +165,11 @@ func (self *Param) checkRedirect(req *http.Request, via []*http.Request) error {
 	}
 	return nil
 }
+
+// 返回请求自带的cookie容器，未指定时返回下载器的默认容器
+func (self *Param) cookieJar(def http.CookieJar) http.CookieJar {
+	if self.jar != nil {
+		return self.jar
+	}
+	return def
+}
@@ -37,7 +37,7 @@ type (
 		PhantomjsFile string            //Phantomjs完整文件名
 		TempJsDir     string            //临时js存放目录
 		jsFileMap     map[string]string //已存在的js文件
-		CookieJar     *cookiejar.Jar
+		CookieJar     http.CookieJar
 	}
 	// Response 用于解析Phantomjs的响应内容
 	Response struct {
@@ -59,7 +59,7 @@ type (
 	}
 )
 
-func NewPhantom(phantomjsFile, tempJsDir string, jar ...*cookiejar.Jar) Surfer {
+func NewPhantom(phantomjsFile, tempJsDir string, jar ...http.CookieJar) Surfer {
 	phantom := &Phantom{
 		PhantomjsFile: phantomjsFile,
 		TempJsDir:     tempJsDir,
@@ -103,8 +103,9 @@ func (self *Phantom) Download(req Request) (resp *http.Response, err error) {
 	}
 
 	cookie := ""
+	jar := param.cookieJar(self.CookieJar)
 	if req.GetEnableCookie() {
-		httpCookies := self.CookieJar.Cookies(param.url)
+		httpCookies := jar.Cookies(param.url)
 		if len(httpCookies) > 0 {
 			surferCookies := make([]*Cookie, len(httpCookies))
 
@@ -178,16 +179,26 @@ func (self *Phantom) Download(req Request) (resp *http.Response, err error) {
 		}
 		if req.GetEnableCookie() {
 			if rc := resp.Cookies(); len(rc) > 0 {
-				self.CookieJar.SetCookies(param.url, rc)
+				jar.SetCookies(param.url, rc)
 			}
 		}
 		resp.Body = ioutil.NopCloser(strings.NewReader(retResp.Body))
+		// 内容经JSON解析后已为UTF-8，据此修正响应头的编码声明，避免按原网页编码重复转码
+		mediaType, params, e := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if e != nil {
+			mediaType, params = "text/html", map[string]string{}
+		}
+		params["charset"] = "utf-8"
+		resp.Header.Set("Content-Type", mime.FormatMediaType(mediaType, params))
 		break
 	}
 
 	if err == nil {
 		resp.StatusCode = http.StatusOK
 		resp.Status = http.StatusText(http.StatusOK)
+		if param.rawHook != nil {
+			param.rawHook(resp)
+		}
 	} else {
 		resp.StatusCode = http.StatusBadGateway
 		resp.Status = err.Error()
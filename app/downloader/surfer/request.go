@@ -49,6 +49,25 @@ type (
 		GetDownloaderID() int
 	}
 
+	// CookieJarRequest 自带cookie容器的请求，启用cookie时使用该容器代替下载器的默认容器
+	CookieJarRequest interface {
+		Request
+		GetCookieJar() http.CookieJar
+	}
+
+	// RawResponseRequest 需处理原始响应的请求，如WARC存档，
+	// 处理函数在解码压缩编码前调用，可替换resp.Body（如读取时同步写入存档）
+	RawResponseRequest interface {
+		Request
+		GetRawHook() func(resp *http.Response)
+	}
+
+	// ResumeRequest 可续传的请求，起始字节大于0时以Range请求其后的部分
+	ResumeRequest interface {
+		Request
+		GetResumeAt() int64
+	}
+
 	// 默认实现的Request
 	DefaultRequest struct {
 		// url (必须填写)
@@ -0,0 +1,208 @@
+package surfer
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ServerResolver 直接向上游DNS服务器查询的解析器，可获得记录的TTL，
+// 优先使用UDP，响应被截断时改用TCP，服务器失败时依次尝试下一个
+type ServerResolver struct {
+	Servers []string      // 如"8.8.8.8:53"，未指定端口时为53
+	Timeout time.Duration // 每次查询的超时时长
+}
+
+// NewServerResolver 创建使用指定上游DNS服务器的解析器，timeout为0时为5秒
+func NewServerResolver(timeout time.Duration, servers ...string) *ServerResolver {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	self := &ServerResolver{Timeout: timeout}
+	for _, s := range servers {
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			s = net.JoinHostPort(s, "53")
+		}
+		self.Servers = append(self.Servers, s)
+	}
+	return self
+}
+
+// Lookup 同时查询A及AAAA记录，TTL取所有记录中的最小值
+func (self *ServerResolver) Lookup(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	name, err := dnsmessage.NewName(host + ".")
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: host}
+	}
+	var (
+		wg      sync.WaitGroup
+		results [2]struct {
+			ips []net.IP
+			ttl time.Duration
+			err error
+		}
+	)
+	for i, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		wg.Add(1)
+		go func(i int, qtype dnsmessage.Type) {
+			defer wg.Done()
+			r := &results[i]
+			r.ips, r.ttl, r.err = self.query(ctx, host, dnsmessage.Question{Name: name, Type: qtype, Class: dnsmessage.ClassINET})
+		}(i, qtype)
+	}
+	wg.Wait()
+
+	var ips []net.IP
+	ttl := NoTTL
+	for _, r := range results {
+		if r.err != nil {
+			continue
+		}
+		ips = append(ips, r.ips...)
+		if len(r.ips) > 0 && (ttl < 0 || r.ttl < ttl) {
+			ttl = r.ttl
+		}
+	}
+	if len(ips) > 0 {
+		return ips, ttl, nil
+	}
+	// 均无记录时，优先返回域名不存在的错误
+	for _, r := range results {
+		if r.err != nil {
+			return nil, 0, r.err
+		}
+	}
+	return nil, 0, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+// 依次向各服务器查询，直至得到有效响应
+func (self *ServerResolver) query(ctx context.Context, host string, q dnsmessage.Question) (ips []net.IP, ttl time.Duration, err error) {
+	err = &net.DNSError{Err: "no DNS servers", Name: host}
+	for _, server := range self.Servers {
+		var msg *dnsmessage.Message
+		msg, err = self.exchange(ctx, server, q)
+		if err != nil {
+			err = &net.DNSError{Err: err.Error(), Name: host, Server: server, IsTimeout: isTimeout(err)}
+			continue
+		}
+		switch msg.RCode {
+		case dnsmessage.RCodeSuccess:
+		case dnsmessage.RCodeNameError:
+			return nil, 0, &net.DNSError{Err: "no such host", Name: host, Server: server, IsNotFound: true}
+		default:
+			err = &net.DNSError{Err: "server misbehaving: " + msg.RCode.String(), Name: host, Server: server}
+			continue
+		}
+		ttl = NoTTL
+		for _, a := range msg.Answers {
+			var ip net.IP
+			switch body := a.Body.(type) {
+			case *dnsmessage.AResource:
+				ip = net.IP(append([]byte(nil), body.A[:]...))
+			case *dnsmessage.AAAAResource:
+				ip = net.IP(append([]byte(nil), body.AAAA[:]...))
+			}
+			// CNAME链上各记录的TTL均计入
+			if d := time.Duration(a.Header.TTL) * time.Second; ttl < 0 || d < ttl {
+				ttl = d
+			}
+			if ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+		return ips, ttl, nil
+	}
+	return nil, 0, err
+}
+
+// 向服务器发送查询，UDP响应被截断时改用TCP
+func (self *ServerResolver) exchange(ctx context.Context, server string, q dnsmessage.Question) (*dnsmessage.Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, self.Timeout)
+	defer cancel()
+	msg, err := self.exchangeOn(ctx, "udp", server, q)
+	if err == nil && msg.Truncated {
+		msg, err = self.exchangeOn(ctx, "tcp", server, q)
+	}
+	return msg, err
+}
+
+func (self *ServerResolver) exchangeOn(ctx context.Context, network, server string, q dnsmessage.Question) (*dnsmessage.Message, error) {
+	id := uint16(rand.Uint32())
+	b := dnsmessage.NewBuilder(make([]byte, 2, 514), dnsmessage.Header{ID: id, RecursionDesired: true})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(q); err != nil {
+		return nil, err
+	}
+	req, err := b.Finish()
+	if err != nil {
+		return nil, err
+	}
+
+	var d net.Dialer
+	c, err := d.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+	}
+
+	var resp []byte
+	if network == "tcp" {
+		// TCP消息以2字节长度开头
+		binary.BigEndian.PutUint16(req, uint16(len(req)-2))
+		if _, err = c.Write(req); err != nil {
+			return nil, err
+		}
+		var l [2]byte
+		if _, err = io.ReadFull(c, l[:]); err != nil {
+			return nil, err
+		}
+		resp = make([]byte, binary.BigEndian.Uint16(l[:]))
+		if _, err = io.ReadFull(c, resp); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err = c.Write(req[2:]); err != nil {
+			return nil, err
+		}
+		resp = make([]byte, 4096)
+		for {
+			n, err := c.Read(resp)
+			if err != nil {
+				return nil, err
+			}
+			var msg dnsmessage.Message
+			// 忽略ID或问题不符的响应
+			if msg.Unpack(resp[:n]) != nil || msg.ID != id || !msg.Response || len(msg.Questions) != 1 || msg.Questions[0] != q {
+				continue
+			}
+			return &msg, nil
+		}
+	}
+
+	var msg dnsmessage.Message
+	if err = msg.Unpack(resp); err != nil {
+		return nil, err
+	}
+	if msg.ID != id || !msg.Response {
+		return nil, errors.New("invalid DNS response")
+	}
+	return &msg, nil
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -15,29 +15,22 @@
 package surfer
 
 import (
-	"compress/flate"
-	"compress/gzip"
-	"compress/zlib"
-	"crypto/tls"
-	"io"
+	"context"
 	"math/rand"
-	"net"
 	"net/http"
 	"net/http/cookiejar"
-	"strings"
 	"time"
 
 	"github.com/molast/crawler-core/app/downloader/surfer/agent"
-	"github.com/molast/crawler-core/common/goutil"
 )
 
 // Surf is the default Download implementation.
 type Surf struct {
-	CookieJar *cookiejar.Jar
+	CookieJar http.CookieJar
 }
 
 // New 创建一个Surf下载器
-func New(jar ...*cookiejar.Jar) Surfer {
+func New(jar ...http.CookieJar) Surfer {
 	s := new(Surf)
 	if len(jar) != 0 {
 		s.CookieJar = jar[0]
@@ -53,29 +46,17 @@ func (self *Surf) Download(req Request) (resp *http.Response, err error) {
 	if err != nil {
 		return nil, err
 	}
-	param.header.Set("Connection", "close")
+	if param.header.Get("Accept-Encoding") == "" {
+		param.header.Set("Accept-Encoding", acceptEncoding)
+	}
 	param.client = self.buildClient(param)
 	resp, err = self.httpRequest(param)
 
 	if err == nil {
-		switch resp.Header.Get("Content-Encoding") {
-		case "gzip":
-			var gzipReader *gzip.Reader
-			gzipReader, err = gzip.NewReader(resp.Body)
-			if err == nil {
-				resp.Body = gzipReader
-			}
-
-		case "deflate":
-			resp.Body = flate.NewReader(resp.Body)
-
-		case "zlib":
-			var readCloser io.ReadCloser
-			readCloser, err = zlib.NewReader(resp.Body)
-			if err == nil {
-				resp.Body = readCloser
-			}
+		if param.rawHook != nil {
+			param.rawHook(resp)
 		}
+		err = DecodeBody(resp)
 	}
 
 	resp = param.writeback(resp)
@@ -83,83 +64,18 @@ func (self *Surf) Download(req Request) (resp *http.Response, err error) {
 	return
 }
 
-var dnsCache = &DnsCache{ipPortLib: goutil.AtomicMap()}
-
-// DnsCache DNS cache
-type DnsCache struct {
-	ipPortLib goutil.Map
-}
-
-// Reg registers ipPort to DNS cache.
-func (d *DnsCache) Reg(addr, ipPort string) {
-	d.ipPortLib.Store(addr, ipPort)
-}
-
-// Del deletes ipPort from DNS cache.
-func (d *DnsCache) Del(addr string) {
-	d.ipPortLib.Delete(addr)
-}
-
-// Query queries ipPort from DNS cache.
-func (d *DnsCache) Query(addr string) (string, bool) {
-	ipPort, ok := d.ipPortLib.Load(addr)
-	if !ok {
-		return "", false
-	}
-	return ipPort.(string), true
-}
-
 // buildClient creates, configures, and returns a *http.Client type.
+// 连接由按协议及代理共享的Transport复用，connTimeout限制整个请求（含读取响应）的时长。
 func (self *Surf) buildClient(param *Param) *http.Client {
 	client := &http.Client{
 		CheckRedirect: param.checkRedirect,
+		Transport:     transports.get(param),
+		Timeout:       param.connTimeout,
 	}
 
 	if param.enableCookie {
-		client.Jar = self.CookieJar
-	}
-
-	transport := &http.Transport{
-		Dial: func(network, addr string) (net.Conn, error) {
-			var (
-				c          net.Conn
-				err        error
-				ipPort, ok = dnsCache.Query(addr)
-			)
-			if !ok {
-				ipPort = addr
-				defer func() {
-					if err == nil {
-						dnsCache.Reg(addr, c.RemoteAddr().String())
-					}
-				}()
-			} else {
-				defer func() {
-					if err != nil {
-						dnsCache.Del(addr)
-					}
-				}()
-			}
-			c, err = net.DialTimeout(network, ipPort, param.dialTimeout)
-			if err != nil {
-				return nil, err
-			}
-			if param.connTimeout > 0 {
-				c.SetDeadline(time.Now().Add(param.connTimeout))
-			}
-			return c, nil
-		},
-	}
-
-	if param.proxy != nil {
-		transport.Proxy = http.ProxyURL(param.proxy)
-	}
-
-	if strings.ToLower(param.url.Scheme) == "https" {
-		transport.TLSClientConfig = &tls.Config{RootCAs: nil, InsecureSkipVerify: true}
-		transport.DisableCompression = true
+		client.Jar = param.cookieJar(self.CookieJar)
 	}
-	client.Transport = transport
 	return client
 }
 
@@ -171,6 +87,7 @@ func (self *Surf) httpRequest(param *Param) (resp *http.Response, err error) {
 	}
 
 	req.Header = param.header
+	req = req.WithContext(context.WithValue(req.Context(), dialTimeoutKey{}, param.dialTimeout))
 
 	if param.tryTimes <= 0 {
 		for {
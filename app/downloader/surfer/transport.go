@@ -0,0 +1,239 @@
+package surfer
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+type (
+	// TransportOptions Surf共享连接池的配置
+	TransportOptions struct {
+		MaxIdleConns        int           // 所有主机的最大空闲连接数，0为不限
+		MaxIdleConnsPerHost int           // 每个主机的最大空闲连接数
+		MaxConnsPerHost     int           // 每个主机的最大连接数（含正在使用的），0为不限
+		IdleConnTimeout     time.Duration // 空闲连接的保持时长，0为不限
+		DisableKeepAlives   bool          // 禁用长连接，每个请求使用新连接
+		DisableHTTP2        bool          // 禁用HTTP/2
+	}
+	// 按代理及协议复用的Transport池，闲置过久的Transport（如已弃用的代理）将被移除
+	transportPool struct {
+		opts       TransportOptions
+		transports map[string]*pooledTransport // [协议+代理]Transport
+		swept      time.Time                   // 上次清理闲置Transport的时刻
+		sync.Mutex
+	}
+	pooledTransport struct {
+		*http.Transport
+		used time.Time // 最近一次获取的时刻
+	}
+	// 请求上下文中保存创建连接超时的键
+	dialTimeoutKey struct{}
+)
+
+// DefaultTransportOptions 默认的连接池配置
+var DefaultTransportOptions = TransportOptions{
+	MaxIdleConns:        512,
+	MaxIdleConnsPerHost: 16,
+	IdleConnTimeout:     90 * time.Second,
+}
+
+// Transport闲置超过该时长后移除并关闭其空闲连接，及清理闲置Transport的间隔
+const (
+	transportExpire = 10 * time.Minute
+	transportSweep  = time.Minute
+)
+
+var transports = &transportPool{
+	opts:       DefaultTransportOptions,
+	transports: make(map[string]*pooledTransport),
+}
+
+// SetTransportOptions 设置Surf共享连接池，并关闭已有的空闲连接
+func SetTransportOptions(opts TransportOptions) {
+	transports.Lock()
+	defer transports.Unlock()
+	for _, t := range transports.transports {
+		t.CloseIdleConnections()
+	}
+	transports.opts = opts
+	transports.transports = make(map[string]*pooledTransport)
+}
+
+// Transport 返回与目标Url的协议及代理对应的共享Transport，proxy为空时直连，
+// 用于下载器以外需与Surf一致地经代理访问的场合，如下载robots.txt
+func Transport(rawurl, proxy string) (*http.Transport, error) {
+	target, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	var proxyURL *url.URL
+	if proxy != "" {
+		if proxyURL, err = url.Parse(proxy); err != nil {
+			return nil, err
+		}
+	}
+	return transports.getFor(target, proxyURL), nil
+}
+
+// 获取与请求的协议及代理对应的共享Transport
+func (self *transportPool) get(param *Param) *http.Transport {
+	return self.getFor(param.url, param.proxy)
+}
+
+func (self *transportPool) getFor(target, proxyURL *url.URL) *http.Transport {
+	https := strings.ToLower(target.Scheme) == "https"
+	key := target.Scheme
+	if proxyURL != nil {
+		key += "|" + proxyURL.String()
+	}
+
+	now := time.Now()
+	self.Lock()
+	defer self.Unlock()
+	if now.Sub(self.swept) >= transportSweep {
+		self.sweep(now)
+	}
+	if t, ok := self.transports[key]; ok {
+		t.used = now
+		return t.Transport
+	}
+	t := &http.Transport{
+		DialContext:         dialContext,
+		MaxIdleConns:        self.opts.MaxIdleConns,
+		MaxIdleConnsPerHost: self.opts.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     self.opts.MaxConnsPerHost,
+		IdleConnTimeout:     self.opts.IdleConnTimeout,
+		DisableKeepAlives:   self.opts.DisableKeepAlives,
+		ForceAttemptHTTP2:   !self.opts.DisableHTTP2,
+		DisableCompression:  true, // 由Surf统一声明及解码压缩编码
+	}
+	if proxyURL != nil {
+		switch strings.ToLower(proxyURL.Scheme) {
+		case "socks5", "socks5h":
+			t.DialContext = socksDialContext(proxyURL)
+		default:
+			t.Proxy = http.ProxyURL(proxyURL)
+		}
+	}
+	if https {
+		t.TLSClientConfig = &tls.Config{RootCAs: nil, InsecureSkipVerify: true}
+	}
+	self.transports[key] = &pooledTransport{Transport: t, used: now}
+	return t
+}
+
+// 移除闲置超过transportExpire的Transport，并关闭其空闲连接
+func (self *transportPool) sweep(now time.Time) {
+	self.swept = now
+	for key, t := range self.transports {
+		if now.Sub(t.used) >= transportExpire {
+			t.CloseIdleConnections()
+			delete(self.transports, key)
+		}
+	}
+}
+
+// 经SOCKS5代理创建连接：socks5在本地解析目标主机，socks5h由代理服务器解析
+func socksDialContext(proxyURL *url.URL) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	var auth *proxy.Auth
+	if proxyURL.User != nil {
+		auth = &proxy.Auth{User: proxyURL.User.Username()}
+		auth.Password, _ = proxyURL.User.Password()
+	}
+	localDNS := strings.ToLower(proxyURL.Scheme) == "socks5"
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), "1080")
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if localDNS {
+			host, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			if net.ParseIP(host) == nil {
+				ips, err := currentDnsCache().Resolve(ctx, host)
+				if err != nil {
+					return nil, err
+				}
+				// 优先使用IPv4地址
+				ip := ips[0]
+				for _, v := range ips {
+					if v.To4() != nil {
+						ip = v
+						break
+					}
+				}
+				addr = net.JoinHostPort(ip.String(), port)
+			}
+		}
+		dialer, err := proxy.SOCKS5("tcp", proxyAddr, auth, forwardDialer{})
+		if err != nil {
+			return nil, err
+		}
+		return dialer.(proxy.ContextDialer).DialContext(ctx, network, addr)
+	}
+}
+
+// 连接SOCKS5代理服务器时使用的Dialer
+type forwardDialer struct{}
+
+func (forwardDialer) Dial(network, addr string) (net.Conn, error) {
+	return dialContext(context.Background(), network, addr)
+}
+
+func (forwardDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return dialContext(ctx, network, addr)
+}
+
+// 创建连接，超时时长取自请求上下文：域名经DNS缓存解析，从轮到的IP开始依次尝试，
+// 均失败时移除该域名的缓存
+func dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	timeout, _ := ctx.Value(dialTimeoutKey{}).(time.Duration)
+	dialer := &net.Dialer{Timeout: timeout}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	cache := currentDnsCache()
+	ips, err := cache.Resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		if !matchNetwork(network, ip) {
+			continue
+		}
+		var c net.Conn
+		if c, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port)); err == nil {
+			return c, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+	}
+	if err == nil {
+		err = &net.DNSError{Err: "no suitable address", Name: host}
+	}
+	cache.Del(host)
+	return nil, err
+}
+
+// IP是否适用于tcp4、tcp6等指定了IP版本的网络
+func matchNetwork(network string, ip net.IP) bool {
+	switch network[len(network)-1] {
+	case '4':
+		return ip.To4() != nil
+	case '6':
+		return ip.To4() == nil
+	}
+	return true
+}
@@ -0,0 +1,155 @@
+package surfer_test
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/molast/crawler-core/app/downloader/request"
+	"github.com/molast/crawler-core/app/downloader/surfer"
+)
+
+func TestTransportReuse(t *testing.T) {
+	var conns int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	s := surfer.New()
+	for i := 0; i < 3; i++ {
+		req := &request.Request{Url: srv.URL, Rule: "test"}
+		req.Prepare()
+		resp, err := s.Download(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(b) != "ok" {
+			t.Fatalf("body = %q", b)
+		}
+	}
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Fatalf("%d connections created, want 1", n)
+	}
+}
+
+func TestTransportHTTP2(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	req := &request.Request{Url: srv.URL, Rule: "test"}
+	req.Prepare()
+	resp, err := surfer.New().Download(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if b, _ := ioutil.ReadAll(resp.Body); string(b) != "HTTP/2.0" {
+		t.Fatalf("proto = %q", b)
+	}
+}
+
+// 仅支持无认证CONNECT命令的SOCKS5代理，记录收到的目标地址类型
+func startSocks5(t *testing.T, atyps chan<- byte) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				buf := make([]byte, 262)
+				// 协商认证方式
+				if _, err := io.ReadFull(c, buf[:2]); err != nil {
+					return
+				}
+				io.ReadFull(c, buf[:buf[1]])
+				c.Write([]byte{5, 0})
+				// 请求：VER CMD RSV ATYP
+				if _, err := io.ReadFull(c, buf[:4]); err != nil {
+					return
+				}
+				atyp := buf[3]
+				var host string
+				switch atyp {
+				case 1:
+					io.ReadFull(c, buf[:4])
+					host = net.IP(buf[:4]).String()
+				case 3:
+					io.ReadFull(c, buf[:1])
+					n := int(buf[0])
+					io.ReadFull(c, buf[:n])
+					host = string(buf[:n])
+				default:
+					return
+				}
+				io.ReadFull(c, buf[:2])
+				port := int(buf[0])<<8 | int(buf[1])
+				atyps <- atyp
+				target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+				if err != nil {
+					c.Write([]byte{5, 4, 0, 1, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer target.Close()
+				c.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+				go io.Copy(target, c)
+				io.Copy(c, target)
+			}(c)
+		}
+	}()
+	return l
+}
+
+func TestSocks5Proxy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	atyps := make(chan byte, 10)
+	l := startSocks5(t, atyps)
+	defer l.Close()
+
+	// socks5在本地解析域名，socks5h由代理解析
+	for scheme, want := range map[string]byte{"socks5": 1, "socks5h": 3} {
+		req := &request.Request{Url: "http://localhost:" + port + "/", Rule: "test", TryTimes: 1}
+		req.Prepare()
+		req.Proxy = scheme + "://" + l.Addr().String()
+		resp, err := surfer.New().Download(req)
+		if err != nil {
+			t.Fatalf("%v: %v", scheme, err)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(b) != "ok" {
+			t.Fatalf("%v: body = %q", scheme, b)
+		}
+		if atyp := <-atyps; atyp != want {
+			t.Fatalf("%v: address type = %d, want %d", scheme, atyp, want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package warc
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// Reader 依次读取WARC文件中的记录，自动识别gzip压缩（含逐条压缩）
+type Reader struct {
+	src    *countReader
+	br     *bufio.Reader // 读取src
+	gz     *gzip.Reader  // gzip压缩时当前成员的解压器，未压缩时为nil
+	rr     *bufio.Reader // 读取记录：gz或br
+	tp     *textproto.Reader
+	member int64 // 当前gzip成员在文件中的偏移
+	index  int   // 下一条记录在当前gzip成员中的序号
+	rest   int64 // 当前记录尚未读取的内容块字节数
+}
+
+// 记录在文件中的位置：gzip压缩时为所在成员的偏移及在成员中的序号，未压缩时为记录的偏移
+type position struct {
+	offset int64
+	index  int
+}
+
+// 统计已读取的字节数
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (self *countReader) Read(p []byte) (int, error) {
+	n, err := self.r.Read(p)
+	self.n += int64(n)
+	return n, err
+}
+
+// NewReader 创建Reader，r为gzip压缩（含逐条压缩）或未压缩的WARC数据
+func NewReader(r io.Reader) (*Reader, error) {
+	self := &Reader{src: &countReader{r: r}}
+	self.br = bufio.NewReader(self.src)
+	self.rr = self.br
+	if magic, err := self.br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(self.br)
+		if err != nil {
+			return nil, err
+		}
+		// 逐个成员读取，以记录各成员的偏移
+		gz.Multistream(false)
+		self.gz = gz
+		self.rr = bufio.NewReader(gz)
+	}
+	self.tp = textproto.NewReader(self.rr)
+	return self, nil
+}
+
+// Next 返回下一条记录，读取完毕时返回io.EOF
+func (self *Reader) Next() (*Record, error) {
+	header, _, err := self.next()
+	if err != nil {
+		return nil, err
+	}
+	content := make([]byte, self.rest)
+	if _, err = io.ReadFull(self.content(), content); err != nil {
+		return nil, err
+	}
+	return &Record{Header: header, Content: content}, nil
+}
+
+// 读取下一条记录的记录头及位置，其内容块由content读取
+func (self *Reader) next() (http.Header, position, error) {
+	// 跳过上一条记录未读取的内容块
+	if self.rest > 0 {
+		if _, err := io.CopyN(io.Discard, self.rr, self.rest); err != nil {
+			return nil, position{}, err
+		}
+		self.rest = 0
+	}
+	var line string
+	var pos position
+	// 跳过记录间的空行
+	for line == "" {
+		if self.gz == nil {
+			pos = position{offset: self.src.n - int64(self.br.Buffered())}
+		} else {
+			pos = position{offset: self.member, index: self.index}
+		}
+		var err error
+		line, err = self.tp.ReadLine()
+		if err == io.EOF && self.gz != nil {
+			if err = self.nextMember(); err == nil {
+				continue
+			}
+		}
+		if err != nil {
+			return nil, position{}, err
+		}
+	}
+	if !strings.HasPrefix(line, "WARC/1.") {
+		return nil, position{}, fmt.Errorf("warc: 无效的版本行 %q", line)
+	}
+	header, err := self.tp.ReadMIMEHeader()
+	if err != nil {
+		return nil, position{}, err
+	}
+	length, err := strconv.ParseInt(header.Get(HEADER_CONTENT_LENGTH), 10, 64)
+	if err != nil || length < 0 {
+		return nil, position{}, errors.New("warc: 无效的Content-Length")
+	}
+	self.rest = length
+	self.index++
+	return http.Header(header), pos, nil
+}
+
+// 当前记录的内容块
+func (self *Reader) content() io.Reader {
+	return &contentReader{r: self}
+}
+
+// 切换到下一个gzip成员，已无成员时返回io.EOF
+func (self *Reader) nextMember() error {
+	if _, err := self.br.Peek(1); err != nil {
+		return err
+	}
+	self.member = self.src.n - int64(self.br.Buffered())
+	if err := self.gz.Reset(self.br); err != nil {
+		return err
+	}
+	self.gz.Multistream(false)
+	self.rr.Reset(self.gz)
+	self.index = 0
+	return nil
+}
+
+// 读取当前记录的内容块
+type contentReader struct {
+	r *Reader
+}
+
+func (self *contentReader) Read(p []byte) (int, error) {
+	if self.r.rest <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > self.r.rest {
+		p = p[:self.r.rest]
+	}
+	n, err := self.r.rr.Read(p)
+	self.r.rest -= int64(n)
+	if err == io.EOF && self.r.rest > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
@@ -0,0 +1,165 @@
+// Package warc 读写WARC 1.1格式的存档文件（ISO 28500:2017）
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const VERSION = "WARC/1.1"
+
+// 记录类型
+const (
+	WARCINFO = "warcinfo"
+	REQUEST  = "request"
+	RESPONSE = "response"
+)
+
+// 记录头字段
+const (
+	HEADER_TYPE           = "WARC-Type"
+	HEADER_RECORD_ID      = "WARC-Record-ID"
+	HEADER_DATE           = "WARC-Date"
+	HEADER_TARGET_URI     = "WARC-Target-URI"
+	HEADER_CONCURRENT_TO  = "WARC-Concurrent-To"
+	HEADER_WARCINFO_ID    = "WARC-Warcinfo-ID"
+	HEADER_FILENAME       = "WARC-Filename"
+	HEADER_BLOCK_DIGEST   = "WARC-Block-Digest"
+	HEADER_PAYLOAD_DIGEST = "WARC-Payload-Digest"
+	HEADER_CONTENT_TYPE   = "Content-Type"
+	HEADER_CONTENT_LENGTH = "Content-Length"
+	HEADER_TRUNCATED      = "WARC-Truncated"
+	// 扩展字段：发生重定向时，原始请求的Url
+	HEADER_REQUEST_URL = "Crawler-Request-Url"
+)
+
+// Record 一条WARC记录
+type Record struct {
+	Header  http.Header // 记录头，键名按MIME规则规范化（如Warc-Type），应使用Get读取
+	Content []byte      // 记录内容块
+}
+
+// Type 返回记录类型
+func (self *Record) Type() string {
+	return self.Header.Get(HEADER_TYPE)
+}
+
+// TargetURI 返回记录对应的Url
+func (self *Record) TargetURI() string {
+	return self.Header.Get(HEADER_TARGET_URI)
+}
+
+// Date 返回记录的创建时刻
+func (self *Record) Date() time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, self.Header.Get(HEADER_DATE))
+	return t
+}
+
+// Response 将response记录的内容块解析为HTTP响应，req为对应的请求，可为nil
+func (self *Record) Response(req *http.Request) (*http.Response, error) {
+	if self.Type() != RESPONSE {
+		return nil, fmt.Errorf("warc: %v记录不是response记录", self.Type())
+	}
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(self.Content)), req)
+}
+
+// 按字段顺序写出记录，内容块读取自content，共length字节，其后以两个CRLF结尾
+func writeRecord(w io.Writer, fields [][2]string, content io.Reader, length int64) (int64, error) {
+	var buf bytes.Buffer
+	buf.WriteString(VERSION + "\r\n")
+	for _, f := range fields {
+		if f[1] == "" {
+			continue
+		}
+		buf.WriteString(f[0] + ": " + f[1] + "\r\n")
+	}
+	buf.WriteString(HEADER_CONTENT_LENGTH + ": " + strconv.FormatInt(length, 10) + "\r\n\r\n")
+	n, err := w.Write(buf.Bytes())
+	written := int64(n)
+	if err != nil {
+		return written, err
+	}
+	m, err := io.CopyN(w, content, length)
+	written += m
+	if err != nil {
+		return written, err
+	}
+	n, err = io.WriteString(w, "\r\n\r\n")
+	return written + int64(n), err
+}
+
+// 返回形如sha1:BASE32的摘要
+func digest(b []byte) string {
+	h := sha1.New()
+	h.Write(b)
+	return sumString(h)
+}
+
+func sumString(h hash.Hash) string {
+	return "sha1:" + base32.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// 返回形如<urn:uuid:...>的随机记录ID
+func newRecordID() string {
+	var u [16]byte
+	rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
+
+// WARC-Date，精确到微秒的UTC时刻
+func formatDate(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000Z")
+}
+
+// 将HTTP请求序列化为request记录的内容块
+func requestBlock(req *http.Request, body string) []byte {
+	var buf bytes.Buffer
+	uri := req.URL.RequestURI()
+	fmt.Fprintf(&buf, "%s %s HTTP/1.1\r\n", valueOr(req.Method, http.MethodGet), uri)
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	buf.WriteString("Host: " + host + "\r\n")
+	req.Header.WriteSubset(&buf, map[string]bool{"Host": true})
+	buf.WriteString("\r\n")
+	buf.WriteString(body)
+	return buf.Bytes()
+}
+
+// 将HTTP响应的状态行及响应头序列化为response记录内容块的开头，其后为原始的响应实体，
+// 保留Content-Encoding及Content-Length，实体已去除分块传输编码，因此不含Transfer-Encoding
+func responseHead(resp *http.Response) []byte {
+	var buf bytes.Buffer
+	proto := resp.Proto
+	if !strings.HasPrefix(proto, "HTTP/") {
+		proto = "HTTP/1.1"
+	}
+	status := resp.Status
+	if status == "" {
+		status = strconv.Itoa(resp.StatusCode) + " " + http.StatusText(resp.StatusCode)
+	}
+	buf.WriteString(proto + " " + status + "\r\n")
+	resp.Header.WriteSubset(&buf, map[string]bool{"Transfer-Encoding": true})
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+func valueOr(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
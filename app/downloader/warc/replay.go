@@ -0,0 +1,161 @@
+package warc
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/molast/crawler-core/app/downloader/surfer"
+)
+
+// ErrNotArchived 存档中不存在请求的Url
+var ErrNotArchived = errors.New("WARC存档中不存在该Url")
+
+// Replayer 以WARC存档中的响应代替实际下载，用于对已存档页面重新解析，实现了surfer.Surfer接口；
+// 仅在内存中索引各response记录的位置，下载时再从文件读取
+type Replayer struct {
+	records map[string]location // [Url]response记录的位置，同一Url保留最后一条
+	sync.RWMutex
+}
+
+// response记录所在的文件及位置
+type location struct {
+	fileName string
+	position
+}
+
+// NewReplayer 索引path中的response记录，path为WARC文件或包含WARC文件（.warc、.warc.gz）的目录
+func NewReplayer(path string) (*Replayer, error) {
+	self := &Replayer{records: make(map[string]location)}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return self, self.Load(path)
+	}
+	err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !(strings.HasSuffix(p, ".warc") || strings.HasSuffix(p, FILE_EXT)) {
+			return nil
+		}
+		return self.Load(p)
+	})
+	return self, err
+}
+
+// Load 索引一个WARC文件中的response记录
+func (self *Replayer) Load(fileName string) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := NewReader(f)
+	if err != nil {
+		return err
+	}
+	self.Lock()
+	defer self.Unlock()
+	for {
+		header, pos, err := r.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Get(HEADER_TYPE) != RESPONSE {
+			continue
+		}
+		loc := location{fileName: fileName, position: pos}
+		self.records[header.Get(HEADER_TARGET_URI)] = loc
+		if u := header.Get(HEADER_REQUEST_URL); u != "" {
+			self.records[u] = loc
+		}
+	}
+}
+
+// Len 返回存档中的Url数
+func (self *Replayer) Len() int {
+	self.RLock()
+	defer self.RUnlock()
+	return len(self.records)
+}
+
+// Download 返回请求Url对应的存档响应，响应内容直接读取自存档文件，
+// 并按Content-Encoding解码，与Surf下载的响应一致
+func (self *Replayer) Download(req surfer.Request) (*http.Response, error) {
+	self.RLock()
+	loc, ok := self.records[req.GetUrl()]
+	self.RUnlock()
+	if !ok {
+		return nil, ErrNotArchived
+	}
+	f, err := os.Open(loc.fileName)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := readResponse(f, loc.position, req)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// 读取f中pos处的response记录，关闭响应时关闭f
+func readResponse(f *os.File, pos position, req surfer.Request) (*http.Response, error) {
+	if _, err := f.Seek(pos.offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	r, err := NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	var header http.Header
+	for i := 0; i <= pos.index; i++ {
+		if header, _, err = r.next(); err != nil {
+			return nil, err
+		}
+	}
+	if header.Get(HEADER_TYPE) != RESPONSE {
+		return nil, errors.New("warc: 索引位置不是response记录")
+	}
+	httpReq, err := http.NewRequest(req.GetMethod(), header.Get(HEADER_TARGET_URI), nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header = req.GetHeader()
+	resp, err := http.ReadResponse(bufio.NewReader(r.content()), httpReq)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &fileBody{ReadCloser: resp.Body, file: f}
+	if err = surfer.DecodeBody(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// 读取自存档文件的响应内容，关闭时关闭文件
+type fileBody struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (self *fileBody) Close() error {
+	err := self.ReadCloser.Close()
+	if e := self.file.Close(); err == nil {
+		err = e
+	}
+	return err
+}
@@ -0,0 +1,191 @@
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/molast/crawler-core/app/downloader/request"
+	"github.com/molast/crawler-core/app/downloader/surfer"
+)
+
+func TestWriteAndReplay(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte("body of /z"))
+	gz.Close()
+	gzipped := buf.Bytes()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusFound)
+			return
+		}
+		w.Header().Set("X-Path", r.URL.Path)
+		if r.URL.Path == "/z" {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gzipped)
+			return
+		}
+		w.Write([]byte("body of " + r.URL.Path))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	// 每个文件只容纳一对记录，以测试文件分割
+	w := NewWriter(dir, "test", 1)
+	s := surfer.New()
+	for _, p := range []string{"/a", "/b", "/old", "/z"} {
+		req := &request.Request{Url: srv.URL + p, Rule: "test"}
+		req.Prepare()
+		req.SetRawHook(func(resp *http.Response) {
+			if _, err := w.WriteExchange(req, resp); err != nil {
+				t.Error(err)
+			}
+		})
+		resp, err := s.Download(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 存档不影响响应内容的读取，读取完毕时写入存档
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if want := "body of " + p; p != "/old" && string(b) != want {
+			t.Fatalf("%v: body = %q", p, b)
+		}
+	}
+	w.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+FILE_EXT))
+	if len(files) != 4 {
+		t.Fatalf("files = %v", files)
+	}
+
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, rec.Type())
+		if rec.Type() == RESPONSE && rec.Header.Get(HEADER_PAYLOAD_DIGEST) != digest([]byte("body of /a")) {
+			t.Fatalf("payload digest = %v", rec.Header.Get(HEADER_PAYLOAD_DIGEST))
+		}
+		if rec.Type() == RESPONSE && !bytes.HasSuffix(rec.Content, []byte("body of /a")) {
+			t.Fatalf("response block = %q", rec.Content)
+		}
+		if rec.Date().IsZero() {
+			t.Fatalf("invalid date %q", rec.Header.Get(HEADER_DATE))
+		}
+	}
+	f.Close()
+	if len(types) != 3 || types[0] != WARCINFO || types[1] != RESPONSE || types[2] != REQUEST {
+		t.Fatalf("record types = %v", types)
+	}
+
+	// 压缩编码的响应按原始字节存档
+	f, err = os.Open(files[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, err = NewReader(f); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		rec, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Type() != RESPONSE {
+			continue
+		}
+		if !bytes.HasSuffix(rec.Content, gzipped) || !bytes.Contains(rec.Content, []byte("Content-Encoding: gzip")) {
+			t.Fatalf("gzip response block = %q", rec.Content)
+		}
+		if rec.Header.Get(HEADER_PAYLOAD_DIGEST) != digest(gzipped) {
+			t.Fatalf("gzip payload digest = %v", rec.Header.Get(HEADER_PAYLOAD_DIGEST))
+		}
+		break
+	}
+	f.Close()
+
+	replayer, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, want := range map[string]string{"/b": "body of /b", "/old": "body of /new", "/z": "body of /z"} {
+		req := &request.Request{Url: srv.URL + p, Rule: "test"}
+		req.Prepare()
+		resp, err := replayer.Download(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		if string(b) != want || resp.StatusCode != http.StatusOK {
+			t.Fatalf("%v: %d %q", p, resp.StatusCode, b)
+		}
+	}
+	req := &request.Request{Url: srv.URL + "/missing", Rule: "test"}
+	req.Prepare()
+	if _, err := replayer.Download(req); err != ErrNotArchived {
+		t.Fatalf("missing: err = %v", err)
+	}
+}
+
+func TestWriteErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not here", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	w := NewWriter(dir, "test", 0)
+	req := &request.Request{Url: srv.URL + "/missing", Rule: "test"}
+	req.Prepare()
+	req.SetRawHook(func(resp *http.Response) {
+		if _, err := w.WriteExchange(req, resp); err != nil {
+			t.Error(err)
+		}
+	})
+	resp, err := surfer.New().Download(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 错误状态的响应不解析，仅关闭响应流
+	resp.Body.Close()
+	w.Close()
+
+	if tmps, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(tmps) != 0 {
+		t.Fatalf("spool files left: %v", tmps)
+	}
+	replayer, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = replayer.Download(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound || string(b) != "not here\n" {
+		t.Fatalf("replayed %d %q", resp.StatusCode, b)
+	}
+}
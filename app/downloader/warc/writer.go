@@ -0,0 +1,277 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/molast/crawler-core/app/downloader/surfer"
+	"github.com/molast/crawler-core/logs"
+)
+
+// 存档文件扩展名
+const FILE_EXT = ".warc.gz"
+
+// Writer 将请求及响应写入gzip压缩的WARC文件，
+// 每条记录单独压缩（可随机读取），文件超过指定大小时新建文件
+type Writer struct {
+	dir        string
+	prefix     string
+	maxSize    int64
+	serial     int
+	file       *os.File
+	size       int64
+	warcinfoID string
+	sync.Mutex
+}
+
+// 正在读取的响应内容，同步写入临时文件，读取完毕后作为一对request、response记录写入存档
+type exchange struct {
+	w        *Writer
+	body     io.ReadCloser
+	spool    *os.File      // 响应记录内容块的临时文件
+	buf      *bufio.Writer // 写入spool
+	head     int64         // 内容块中状态行及响应头的字节数
+	size     int64         // 已写入的响应实体字节数
+	block    hash.Hash     // 内容块的摘要
+	payload  hash.Hash     // 响应实体的摘要
+	fields   [][2]string   // 响应记录头（不含摘要及长度）
+	respID   string
+	reqBlock []byte
+	reqField [][2]string
+	url      string
+	err      error // 写入临时文件的错误
+	done     bool
+}
+
+// NewWriter 创建写入dir目录的Writer，文件名为“prefix-时刻-序号.warc.gz”，maxSize<=0时不分割文件
+func NewWriter(dir, prefix string, maxSize int64) *Writer {
+	return &Writer{
+		dir:     dir,
+		prefix:  prefix,
+		maxSize: maxSize,
+	}
+}
+
+// WriteExchange 存档一对request、response记录，req为下载时使用的请求，resp应为解码压缩编码前的原始响应。
+// 响应内容不在内存中缓存：读取时同步写入临时文件，读取完毕或关闭时（未读取的部分随之读取）写入存档，
+// 读取出错时记为截断的记录；返回的响应即resp，其Body已被替换
+func (self *Writer) WriteExchange(req surfer.Request, resp *http.Response) (*http.Response, error) {
+	httpReq := resp.Request
+	if httpReq == nil || httpReq.URL == nil {
+		var err error
+		if httpReq, err = http.NewRequest(req.GetMethod(), req.GetUrl(), nil); err != nil {
+			return resp, err
+		}
+		httpReq.Header = req.GetHeader()
+	}
+	target := httpReq.URL.String()
+	var origin string
+	if target != req.GetUrl() {
+		origin = req.GetUrl()
+	}
+	date := formatDate(time.Now())
+
+	if err := os.MkdirAll(self.dir, 0777); err != nil {
+		return resp, err
+	}
+	spool, err := os.CreateTemp(self.dir, "*.tmp")
+	if err != nil {
+		return resp, err
+	}
+	x := &exchange{
+		w:       self,
+		body:    resp.Body,
+		spool:   spool,
+		buf:     bufio.NewWriter(spool),
+		block:   sha1.New(),
+		payload: sha1.New(),
+		respID:  newRecordID(),
+		url:     req.GetUrl(),
+	}
+	x.fields = [][2]string{
+		{HEADER_TYPE, RESPONSE},
+		{HEADER_RECORD_ID, x.respID},
+		{HEADER_DATE, date},
+		{HEADER_TARGET_URI, target},
+		{HEADER_REQUEST_URL, origin},
+		{HEADER_CONTENT_TYPE, "application/http;msgtype=response"},
+	}
+	x.reqBlock = requestBlock(httpReq, req.GetPostData())
+	x.reqField = [][2]string{
+		{HEADER_TYPE, REQUEST},
+		{HEADER_RECORD_ID, newRecordID()},
+		{HEADER_DATE, date},
+		{HEADER_TARGET_URI, target},
+		{HEADER_CONCURRENT_TO, x.respID},
+		{HEADER_CONTENT_TYPE, "application/http;msgtype=request"},
+		{HEADER_BLOCK_DIGEST, digest(x.reqBlock)},
+	}
+	head := responseHead(resp)
+	x.head = int64(len(head))
+	x.write(head, false)
+	if resp.Body == nil {
+		x.body = http.NoBody
+	}
+	resp.Body = x
+	return resp, nil
+}
+
+// Close 关闭当前文件
+func (self *Writer) Close() error {
+	self.Lock()
+	defer self.Unlock()
+	if self.file == nil {
+		return nil
+	}
+	err := self.file.Close()
+	self.file = nil
+	return err
+}
+
+// 写入一对response、request记录，response记录的内容块读取自spool
+func (self *Writer) writeExchange(x *exchange, truncated string) error {
+	self.Lock()
+	defer self.Unlock()
+	if err := self.rotate(); err != nil {
+		return err
+	}
+	fields := append(x.fields,
+		[2]string{HEADER_WARCINFO_ID, self.warcinfoID},
+		[2]string{HEADER_BLOCK_DIGEST, sumString(x.block)},
+		[2]string{HEADER_PAYLOAD_DIGEST, sumString(x.payload)},
+		[2]string{HEADER_TRUNCATED, truncated},
+	)
+	if _, err := x.spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := self.write(fields, x.spool, x.head+x.size); err != nil {
+		return err
+	}
+	reqFields := append(x.reqField, [2]string{HEADER_WARCINFO_ID, self.warcinfoID})
+	return self.write(reqFields, bytes.NewReader(x.reqBlock), int64(len(x.reqBlock)))
+}
+
+// 尚未打开文件或当前文件已超过指定大小时，新建文件并写入warcinfo记录
+func (self *Writer) rotate() error {
+	if self.file != nil && (self.maxSize <= 0 || self.size < self.maxSize) {
+		return nil
+	}
+	if self.file != nil {
+		self.file.Close()
+		self.file = nil
+	}
+	if err := os.MkdirAll(self.dir, 0777); err != nil {
+		return err
+	}
+	self.serial++
+	name := fmt.Sprintf("%s-%s-%05d%s", self.prefix, time.Now().Format("20060102150405"), self.serial, FILE_EXT)
+	f, err := os.OpenFile(filepath.Join(self.dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	self.file = f
+	self.size = 0
+	self.warcinfoID = newRecordID()
+	info := []byte("software: " + "crawler-core\r\n" + "format: WARC File Format 1.1\r\n" + "conformsTo: https://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/\r\n")
+	return self.write([][2]string{
+		{HEADER_TYPE, WARCINFO},
+		{HEADER_RECORD_ID, self.warcinfoID},
+		{HEADER_DATE, formatDate(time.Now())},
+		{HEADER_FILENAME, name},
+		{HEADER_CONTENT_TYPE, "application/warc-fields"},
+	}, bytes.NewReader(info), int64(len(info)))
+}
+
+// 将一条记录压缩为单独的gzip成员写入当前文件，内容块读取自content，共length字节
+func (self *Writer) write(fields [][2]string, content io.Reader, length int64) error {
+	cw := &countWriter{w: self.file}
+	gz := gzip.NewWriter(cw)
+	_, err := writeRecord(gz, fields, content, length)
+	if e := gz.Close(); err == nil {
+		err = e
+	}
+	self.size += cw.n
+	return err
+}
+
+func (self *exchange) Read(p []byte) (int, error) {
+	n, err := self.body.Read(p)
+	if n > 0 {
+		self.write(p[:n], true)
+	}
+	switch {
+	case err == io.EOF:
+		self.finish("")
+	case err != nil:
+		// 下载中断时记为截断的记录
+		self.finish("disconnect")
+	}
+	return n, err
+}
+
+// Close 读取未读取的部分后写入存档，并关闭原响应流
+func (self *exchange) Close() error {
+	if !self.done {
+		io.Copy(io.Discard, self)
+	}
+	return self.body.Close()
+}
+
+// 写入临时文件并计算摘要，payload表示是否为响应实体
+func (self *exchange) write(p []byte, payload bool) {
+	if self.err != nil || self.done {
+		return
+	}
+	if _, self.err = self.buf.Write(p); self.err != nil {
+		return
+	}
+	self.block.Write(p)
+	if payload {
+		self.payload.Write(p)
+		self.size += int64(len(p))
+	}
+}
+
+// 写入存档并删除临时文件，truncated为截断原因，完整时为空
+func (self *exchange) finish(truncated string) {
+	if self.done {
+		return
+	}
+	self.done = true
+	defer func() {
+		self.spool.Close()
+		os.Remove(self.spool.Name())
+	}()
+	err := self.err
+	if err == nil {
+		err = self.buf.Flush()
+	}
+	if err == nil {
+		err = self.w.writeExchange(self, truncated)
+	}
+	if err != nil {
+		logs.Log.Error(" *     Fail  [WARC存档][%v]: %v\n", self.url, err)
+	}
+}
+
+// 统计写入的字节数
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (self *countWriter) Write(p []byte) (int, error) {
+	n, err := self.w.Write(p)
+	self.n += int64(n)
+	return n, err
+}
@@ -7,7 +7,7 @@ import (
 type (
 	// DataCell 数据存储单元
 	DataCell map[string]interface{}
-	// FileCell 文件存储单元
+	// FileCell 文件存储单元，文件内容已写入临时文件"Path"
 	// FileCell存储的完整文件名为： file/"Dir"/"RuleName"/"time"/"Name"
 	FileCell map[string]interface{}
 )
@@ -35,11 +35,19 @@ func GetDataCell(ruleName string, data map[string]interface{}, url string, paren
 	return cell
 }
 
-func GetFileCell(ruleName, name string, bytes []byte) FileCell {
+// SetDepth 附加请求与根请求的距离，仅在选择输出深度时设置
+func (self DataCell) SetDepth(depth int) DataCell {
+	self["Depth"] = depth
+	return self
+}
+
+func GetFileCell(ruleName, name, path string, size int64, checksum string) FileCell {
 	cell := fileCellPool.Get().(FileCell)
 	cell["RuleName"] = ruleName //存储路径中的一部分
 	cell["Name"] = name         //规定文件名
-	cell["Bytes"] = bytes       //文件内容
+	cell["Path"] = path         //文件内容所在的临时文件
+	cell["Size"] = size         //文件字节数
+	cell["Checksum"] = checksum //文件校验和，如"sha256:..."，未计算时为空
 	return cell
 }
 
@@ -49,12 +57,15 @@ func PutDataCell(cell DataCell) {
 	cell["Url"] = nil
 	cell["ParentUrl"] = nil
 	cell["DownloadTime"] = nil
+	delete(cell, "Depth")
 	dataCellPool.Put(cell)
 }
 
 func PutFileCell(cell FileCell) {
 	cell["RuleName"] = nil
 	cell["Name"] = nil
-	cell["Bytes"] = nil
+	cell["Path"] = nil
+	cell["Size"] = nil
+	cell["Checksum"] = nil
 	fileCellPool.Put(cell)
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/csv"
 	"fmt"
 	"os"
+	"strconv"
 
 	"github.com/molast/crawler-core/common/util"
 	"github.com/molast/crawler-core/config"
@@ -59,6 +60,9 @@ func init() {
 				if self.Spider.OutDefaultField() {
 					th = append(th, "当前链接", "上级链接", "下载时间")
 				}
+				if self.Spider.OutDepthField() {
+					th = append(th, "深度")
+				}
 				sheets[subNamespace].Write(th)
 			}
 			var row []string
@@ -75,6 +79,9 @@ func init() {
 				row = append(row, datacell["ParentUrl"].(string))
 				row = append(row, datacell["DownloadTime"].(string))
 			}
+			if self.Spider.OutDepthField() {
+				row = append(row, strconv.Itoa(datacell["Depth"].(int)))
+			}
 			sheets[subNamespace].Write(row)
 		}
 		return
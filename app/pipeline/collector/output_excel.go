@@ -3,6 +3,7 @@ package collector
 import (
 	"fmt"
 	"os"
+	"strconv"
 
 	"github.com/molast/crawler-core/common/util"
 	"github.com/molast/crawler-core/common/xlsx"
@@ -51,6 +52,9 @@ func init() {
 					row.AddCell().Value = "上级链接"
 					row.AddCell().Value = "下载时间"
 				}
+				if self.Spider.OutDepthField() {
+					row.AddCell().Value = "深度"
+				}
 			}
 
 			row = sheets[subNamespace].AddRow()
@@ -68,6 +72,9 @@ func init() {
 				row.AddCell().Value = datacell["ParentUrl"].(string)
 				row.AddCell().Value = datacell["DownloadTime"].(string)
 			}
+			if self.Spider.OutDepthField() {
+				row.AddCell().Value = strconv.Itoa(datacell["Depth"].(int))
+			}
 		}
 		folder := config.TEXT_DIR + "/" + cache.StartTime.Format("2006-01-02 150405")
 		filename := fmt.Sprintf("%v/%v__%v-%v.xlsx", folder, util.FileNameReplace(self.namespace()), self.sum[0], self.sum[1])
@@ -1,7 +1,6 @@
 package collector
 
 import (
-	"bytes"
 	"io"
 	"os"
 	"path/filepath"
@@ -30,11 +29,15 @@ func (self *Collector) outputFile(file data.FileCell) {
 
 	// 文件名
 	fileName := filepath.Join(dir, util.FileNameReplace(n))
+	// 文件内容所在的临时文件
+	tmpName := file["Path"].(string)
+	size := file["Size"].(int64)
 
 	// 创建/打开目录
 	d, err := os.Stat(dir)
 	if err != nil || !d.IsDir() {
 		if err := os.MkdirAll(dir, 0777); err != nil {
+			os.Remove(tmpName)
 			logs.Log.Error(
 				" *     Fail  [文件下载：%v | KEYIN：%v | 批次：%v]   %v [ERROR]  %v\n",
 				self.Spider.GetName(), self.Spider.GetKeyin(), atomic.LoadUint64(&self.fileBatch), fileName, err,
@@ -43,19 +46,10 @@ func (self *Collector) outputFile(file data.FileCell) {
 		}
 	}
 
-	// 文件不存在就以0777的权限创建文件，如果存在就在写入之前清空内容
-	f, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0777)
-	if err != nil {
-		logs.Log.Error(
-			" *     Fail  [文件下载：%v | KEYIN：%v | 批次：%v]   %v [ERROR]  %v\n",
-			self.Spider.GetName(), self.Spider.GetKeyin(), atomic.LoadUint64(&self.fileBatch), fileName, err,
-		)
-		return
-	}
-
-	size, err := io.Copy(f, bytes.NewReader(file["Bytes"].([]byte)))
-	f.Close()
+	// 将临时文件移至输出目录，已存在时覆盖
+	err = moveFile(tmpName, fileName)
 	if err != nil {
+		os.Remove(tmpName)
 		logs.Log.Error(
 			" *     Fail  [文件下载：%v | KEYIN：%v | 批次：%v]   %v (%s) [ERROR]  %v\n",
 			self.Spider.GetName(), self.Spider.GetKeyin(), atomic.LoadUint64(&self.fileBatch), fileName, bytesSize.Format(uint64(size)), err,
@@ -68,9 +62,41 @@ func (self *Collector) outputFile(file data.FileCell) {
 
 	// 打印报告
 	logs.Log.Informational(" * ")
-	logs.Log.App(
-		" *     [文件下载：%v | KEYIN：%v | 批次：%v]   %v (%s)\n",
-		self.Spider.GetName(), self.Spider.GetKeyin(), atomic.LoadUint64(&self.fileBatch), fileName, bytesSize.Format(uint64(size)),
-	)
+	if checksum, _ := file["Checksum"].(string); checksum != "" {
+		logs.Log.App(
+			" *     [文件下载：%v | KEYIN：%v | 批次：%v]   %v (%s) %v\n",
+			self.Spider.GetName(), self.Spider.GetKeyin(), atomic.LoadUint64(&self.fileBatch), fileName, bytesSize.Format(uint64(size)), checksum,
+		)
+	} else {
+		logs.Log.App(
+			" *     [文件下载：%v | KEYIN：%v | 批次：%v]   %v (%s)\n",
+			self.Spider.GetName(), self.Spider.GetKeyin(), atomic.LoadUint64(&self.fileBatch), fileName, bytesSize.Format(uint64(size)),
+		)
+	}
 	logs.Log.Informational(" * ")
 }
+
+// 移动文件，跨文件系统时复制后删除源文件
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0777)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if e := out.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	in.Close()
+	return os.Remove(src)
+}
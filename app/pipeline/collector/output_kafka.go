@@ -74,6 +74,9 @@ func init() {
 				data["parent_url"] = datacell["ParentUrl"].(string)
 				data["download_time"] = datacell["DownloadTime"].(string)
 			}
+			if self.Spider.OutDepthField() {
+				data["depth"] = datacell["Depth"]
+			}
 			err := sender.Push(data)
 			util.CheckErr(err)
 		}
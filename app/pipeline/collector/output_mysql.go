@@ -2,6 +2,7 @@ package collector
 
 import (
 	"fmt"
+	"strconv"
 	"sync"
 
 	"github.com/molast/crawler-core/common/mysql"
@@ -59,6 +60,9 @@ func init() {
 					if self.Spider.OutDefaultField() {
 						table.AddColumn(`Url VARCHAR(255)`, `ParentUrl VARCHAR(255)`, `DownloadTime VARCHAR(50)`)
 					}
+					if self.Spider.OutDepthField() {
+						table.AddColumn(`Depth INT`)
+					}
 					if err := table.Create(); err != nil {
 						logs.Log.Error("%v", err)
 						continue
@@ -80,6 +84,9 @@ func init() {
 			if self.Spider.OutDefaultField() {
 				data = append(data, datacell["Url"].(string), datacell["ParentUrl"].(string), datacell["DownloadTime"].(string))
 			}
+			if self.Spider.OutDepthField() {
+				data = append(data, strconv.Itoa(datacell["Depth"].(int)))
+			}
 			table.AutoInsert(data)
 		}
 		for _, tab := range mysqls {
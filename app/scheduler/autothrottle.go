@@ -0,0 +1,165 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/molast/crawler-core/app/downloader/request"
+)
+
+const (
+	autoAlpha     = 0.2                    // 延迟及错误率滑动平均的权重
+	autoSlowRatio = 2                      // 平均延迟超过基准延迟的倍数时视为服务端变慢
+	autoDelayUnit = 100 * time.Millisecond // 首次被限流时的最小请求间隔
+)
+
+type (
+	// 自适应并发控制（AIMD），根据响应延迟、限流及超时情况调整单个Spider实例的并发量与请求间隔
+	autoThrottle struct {
+		minConc   int           // 最小并发量
+		maxConc   int           // 最大并发量
+		minDelay  time.Duration // 最小请求间隔
+		maxDelay  time.Duration // 最大请求间隔，<=0为不限
+		conc      int           // 当前并发量
+		delay     time.Duration // 当前请求间隔
+		latency   time.Duration // 响应延迟的滑动平均值
+		baseline  time.Duration // 基准延迟，即观测到的最小响应延迟
+		errRate   float64       // 限流及超时比例的滑动平均值
+		succ      int           // 本轮连续成功的请求数，达到当前并发量时增加并发
+		last      time.Time     // 上次放行请求的时刻
+		decreased time.Time     // 上次降低并发的时刻，避免同一批请求的错误重复降低
+		sync.Mutex
+	}
+	// AutoThrottleStat 自适应并发的当前状态，用于监控
+	AutoThrottleStat struct {
+		Spider      string        // 所属Spider
+		Concurrency int           // 当前并发量
+		Delay       time.Duration // 当前请求间隔
+		Latency     time.Duration // 响应延迟的滑动平均值
+		ErrorRate   float64       // 限流及超时比例的滑动平均值
+	}
+)
+
+func newAutoThrottle(minConc, maxConc int, minDelay, maxDelay time.Duration) *autoThrottle {
+	if minConc <= 0 {
+		minConc = 1
+	}
+	if maxConc < minConc {
+		maxConc = minConc
+	}
+	if maxDelay > 0 && maxDelay < minDelay {
+		maxDelay = minDelay
+	}
+	return &autoThrottle{
+		minConc:  minConc,
+		maxConc:  maxConc,
+		minDelay: minDelay,
+		maxDelay: maxDelay,
+		conc:     minConc,
+		delay:    minDelay,
+	}
+}
+
+// 返回当前并发量及请求间隔是否允许发起新请求，active为正在进行中的请求数
+func (self *autoThrottle) allow(active int) bool {
+	self.Lock()
+	defer self.Unlock()
+	if active >= self.conc {
+		return false
+	}
+	return self.delay <= 0 || time.Since(self.last) >= self.delay
+}
+
+// 记录发起请求的时刻
+func (self *autoThrottle) start() {
+	self.Lock()
+	self.last = time.Now()
+	self.Unlock()
+}
+
+// 根据请求结果调整并发量与请求间隔，kind为错误类型，成功时为空
+func (self *autoThrottle) feedback(latency time.Duration, kind string) {
+	self.Lock()
+	defer self.Unlock()
+
+	switch kind {
+	case request.RETRY_429, request.RETRY_5XX, request.RETRY_TIMEOUT, request.RETRY_BLOCKED:
+		self.errRate = self.errRate*(1-autoAlpha) + autoAlpha
+		self.decrease()
+		return
+	}
+	self.errRate *= 1 - autoAlpha
+	if kind != "" {
+		return
+	}
+
+	if self.latency == 0 {
+		self.latency = latency
+	} else {
+		self.latency = time.Duration(float64(self.latency)*(1-autoAlpha) + float64(latency)*autoAlpha)
+	}
+	if self.baseline == 0 || latency < self.baseline {
+		self.baseline = latency
+	}
+
+	self.succ++
+	if self.succ < self.conc {
+		return
+	}
+	self.succ = 0
+	if self.latency > self.baseline*autoSlowRatio {
+		// 服务端变慢，小幅降低并发
+		if self.conc > self.minConc {
+			self.conc--
+		}
+		return
+	}
+	// 加性增加并发，同时逐步缩短请求间隔
+	if self.conc < self.maxConc {
+		self.conc++
+	}
+	self.delay = self.delay * 3 / 4
+	if self.delay < self.minDelay {
+		self.delay = self.minDelay
+	}
+}
+
+// 乘性降低并发并加大请求间隔，须持有锁
+func (self *autoThrottle) decrease() {
+	self.succ = 0
+	cooldown := self.latency
+	if cooldown < time.Second {
+		cooldown = time.Second
+	}
+	if time.Since(self.decreased) < cooldown {
+		return
+	}
+	self.decreased = time.Now()
+	self.conc /= 2
+	if self.conc < self.minConc {
+		self.conc = self.minConc
+	}
+	if self.delay < autoDelayUnit {
+		self.delay = autoDelayUnit
+	} else {
+		self.delay *= 2
+	}
+	if self.maxDelay > 0 && self.delay > self.maxDelay {
+		self.delay = self.maxDelay
+	}
+	if self.delay < self.minDelay {
+		self.delay = self.minDelay
+	}
+}
+
+func (self *autoThrottle) stat(spiderName string) AutoThrottleStat {
+	self.Lock()
+	defer self.Unlock()
+	return AutoThrottleStat{
+		Spider:      spiderName,
+		Concurrency: self.conc,
+		Delay:       self.delay,
+		Latency:     self.latency,
+		ErrorRate:   self.errRate,
+	}
+}
@@ -0,0 +1,41 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/molast/crawler-core/app/downloader/request"
+)
+
+// 按到期时刻排序的延迟请求队列（最小堆），实现heap.Interface
+type (
+	delayQueue []*delayItem
+	delayItem  struct {
+		due time.Time // 到期时刻，到期前不会被调度
+		req *request.Request
+	}
+)
+
+func (q delayQueue) Len() int {
+	return len(q)
+}
+
+func (q delayQueue) Less(i, j int) bool {
+	return q[i].due.Before(q[j].due)
+}
+
+func (q delayQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *delayQueue) Push(x interface{}) {
+	*q = append(*q, x.(*delayItem))
+}
+
+func (q *delayQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return item
+}
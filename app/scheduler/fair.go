@@ -0,0 +1,100 @@
+package scheduler
+
+import (
+	"sync/atomic"
+)
+
+// 单个Spider实例的资源需求，用于加权公平分配
+type shareDemand struct {
+	weight int // 权重，<=0时按1计算
+	min    int // 最小份额
+	max    int // 最大份额，<=0为不限
+	demand int // 实际需求，即运行中及排队中的请求数
+}
+
+// 加权公平分配total个资源：
+// 先满足各实例的最小份额，剩余资源按权重分配，
+// 每个实例所得不超过其最大份额及实际需求，空闲实例未用的资源借给繁忙的实例。
+func fairShares(total int, demands []shareDemand) []int {
+	alloc := make([]int, len(demands))
+	limit := make([]int, len(demands))
+	for i, d := range demands {
+		limit[i] = d.demand
+		if d.max > 0 && d.max < limit[i] {
+			limit[i] = d.max
+		}
+		alloc[i] = d.min
+		if alloc[i] > limit[i] {
+			alloc[i] = limit[i]
+		}
+		total -= alloc[i]
+	}
+	for total > 0 {
+		var weights int
+		for i, d := range demands {
+			if alloc[i] < limit[i] {
+				weights += d.weightOrOne()
+			}
+		}
+		if weights == 0 {
+			break
+		}
+		var given int
+		for i, d := range demands {
+			if alloc[i] >= limit[i] {
+				continue
+			}
+			n := total * d.weightOrOne() / weights
+			if n == 0 {
+				n = 1
+			}
+			if n > limit[i]-alloc[i] {
+				n = limit[i] - alloc[i]
+			}
+			if n > total-given {
+				n = total - given
+			}
+			alloc[i] += n
+			given += n
+			if given >= total {
+				break
+			}
+		}
+		total -= given
+	}
+	return alloc
+}
+
+func (self shareDemand) weightOrOne() int {
+	if self.weight <= 0 {
+		return 1
+	}
+	return self.weight
+}
+
+// 返回指定spider实例当前分配到的资源量
+func (self *scheduler) share(m *Matrix) int32 {
+	self.RLock()
+	defer self.RUnlock()
+	idx := -1
+	demands := make([]shareDemand, len(self.matrices))
+	for i, matrix := range self.matrices {
+		if matrix == m {
+			idx = i
+		}
+		demands[i] = shareDemand{
+			weight: matrix.weight,
+			min:    matrix.minShare,
+			max:    matrix.maxShare,
+			demand: int(atomic.LoadInt32(&matrix.resCount) + atomic.LoadInt32(&matrix.waiting)),
+		}
+	}
+	if idx < 0 {
+		return int32(cap(self.count))
+	}
+	share := int32(fairShares(cap(self.count), demands)[idx])
+	if share == 0 {
+		share = 1
+	}
+	return share
+}
@@ -1,11 +1,14 @@
 package scheduler
 
 import (
+	"container/heap"
+	"net/http"
 	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/molast/crawler-core/app/aid/frontier"
 	"github.com/molast/crawler-core/app/aid/history"
 	"github.com/molast/crawler-core/app/downloader/request"
 	"github.com/molast/crawler-core/logs"
@@ -15,15 +18,31 @@ import (
 
 // Matrix 一个Spider实例的请求矩阵
 type Matrix struct {
-	maxPage         int64                       // 最大采集页数，以负数形式表示
-	resCount        int32                       // 资源使用情况计数
-	spiderName      string                      // 所属Spider
-	reqs            map[int][]*request.Request  // [优先级]队列，优先级默认为0
-	priorities      []int                       // 优先级顺序，从低到高
-	history         history.Historier           // 历史记录
-	tempHistory     map[string]bool             // 临时记录 [reqUnique(url+method)]true
-	failures        map[string]*request.Request // 历史及本次失败请求
-	hasFaliure      bool                        // 新增：是否有历史爬取失败信息,通知应用层
+	maxPage         int64                         // 最大采集页数，以负数形式表示
+	resCount        int32                         // 资源使用情况计数
+	waiting         int32                         // 队列中等待调度的请求数，不含延迟请求
+	robotsWaiting   int32                         // 等待下载robots.txt的请求数
+	hold            int32                         // 暂停调度的次数，如重新登录期间
+	weight          int                           // 资源分配权重
+	minShare        int                           // 最少分配的资源量
+	maxShare        int                           // 最多分配的资源量，0为不限
+	spiderName      string                        // 所属Spider
+	reqs            map[int]*reqQueue             // [优先级]队列，优先级默认为0
+	priorities      []int                         // 优先级顺序，从低到高
+	history         history.Historier             // 历史记录
+	tempHistory     *history.Pending              // 已加入队列、尚未完成的请求的临时记录 [reqUnique(url+method)]
+	failures        map[string]*request.Request   // 历史及本次失败请求
+	hasFaliure      bool                          // 新增：是否有历史爬取失败信息,通知应用层
+	frontier        *frontier.Frontier            // 持久化请求队列，未开启时为nil
+	ignoreRobots    bool                          // 是否忽略robots.txt协议
+	maxDepth        int                           // 请求的最大深度，0为不限
+	ruleDepth       map[string]int                // [规则名]最大深度，覆盖maxDepth
+	revisit         time.Duration                 // 增量抓取时成功请求的重新抓取间隔，0为不重新抓取
+	resumable       []*request.Request            // 从持久化请求队列中读取的上次未完成请求
+	delayed         delayQueue                    // 等待到期后再调度的请求，如设置了NotBefore或按重试策略延迟重试的请求
+	auto            *autoThrottle                 // 自适应并发控制，未开启时为nil
+	throttled       map[*request.Request][]string // [处理中的请求]每次取出时占用名额的主机限速键
+	giveUp          func(*request.Request)        // 请求最终失败、不再重试时的回调
 	tempHistoryLock sync.RWMutex
 	failureLock     sync.Mutex
 	sync.Mutex
@@ -33,16 +52,31 @@ func newMatrix(spiderName, spiderSubName string, maxPage int64) *Matrix {
 	matrix := &Matrix{
 		spiderName:  spiderName,
 		maxPage:     maxPage,
-		reqs:        make(map[int][]*request.Request),
+		reqs:        make(map[int]*reqQueue),
 		priorities:  []int{},
 		history:     history.New(spiderName, spiderSubName),
-		tempHistory: make(map[string]bool),
+		tempHistory: history.NewPending(),
 		failures:    make(map[string]*request.Request),
 	}
 	if cache.Task.Mode != status.SERVER {
 		matrix.history.ReadSuccess(cache.Task.OutType, cache.Task.SuccessInherit)
 		matrix.history.ReadFailure(cache.Task.OutType, cache.Task.FailureInherit)
 		matrix.setFailures(matrix.history.PullFailure())
+		if cache.Task.FrontierInherit {
+			matrix.openFrontier(spiderName, spiderSubName)
+		}
+	}
+	if cache.Task.AutoThrottle {
+		maxThread := cache.Task.AutoMaxThread
+		if maxThread <= 0 {
+			maxThread = cache.Task.ThreadNum
+		}
+		matrix.auto = newAutoThrottle(
+			cache.Task.AutoMinThread,
+			maxThread,
+			time.Duration(cache.Task.AutoMinDelay)*time.Millisecond,
+			time.Duration(cache.Task.AutoMaxDelay)*time.Millisecond,
+		)
 	}
 	//新增，让外界知道当前启动规则，是否已有历史爬取失败记录
 	if len(matrix.failures) > 0 {
@@ -57,8 +91,91 @@ func (self *Matrix) HasFaliure() bool {
 	return self.hasFaliure
 }
 
+// Resume 将上次中断时持久化的请求重新加入队列，
+// 返回是否从断点恢复（恢复时无需再执行Root）
+func (self *Matrix) Resume() bool {
+	reqs := self.resumable
+	self.resumable = nil
+	if len(reqs) == 0 {
+		return false
+	}
+	logs.Log.Informational(" *     [恢复请求队列]: %v 条\n", len(reqs))
+	for _, req := range reqs {
+		self.Push(req)
+	}
+	return true
+}
+
+// SetIgnoreRobots 设置是否忽略robots.txt协议
+func (self *Matrix) SetIgnoreRobots(ignore bool) *Matrix {
+	self.ignoreRobots = ignore
+	return self
+}
+
+// SetShare 设置资源分配的权重及最少、最多分配的资源量
+func (self *Matrix) SetShare(weight, minShare, maxShare int) *Matrix {
+	self.weight = weight
+	self.minShare = minShare
+	self.maxShare = maxShare
+	return self
+}
+
+// SetRevisit 开启增量抓取模式，成功请求在interval之后可被重新抓取，
+// 并按上次的ETag/Last-Modified发送条件请求
+func (self *Matrix) SetRevisit(interval time.Duration) *Matrix {
+	if interval <= 0 || cache.Task.Mode == status.SERVER {
+		return self
+	}
+	if err := self.history.OpenRevisit(); err != nil {
+		logs.Log.Error(" *     Fail  [打开校验信息记录]: %v\n", err)
+		return self
+	}
+	self.revisit = interval
+	return self
+}
+
+// SetGiveUp 设置请求最终失败（不再重试）时的回调，如清理续传的临时文件
+func (self *Matrix) SetGiveUp(fn func(*request.Request)) *Matrix {
+	self.giveUp = fn
+	return self
+}
+
+// SetMaxDepth 设置请求的最大深度，ruleDepth为各规则单独设置的最大深度
+func (self *Matrix) SetMaxDepth(maxDepth int, ruleDepth map[string]int) *Matrix {
+	self.maxDepth = maxDepth
+	self.ruleDepth = ruleDepth
+	return self
+}
+
+// 请求是否超过其所属规则的最大深度
+func (self *Matrix) tooDeep(req *request.Request) bool {
+	max := self.maxDepth
+	if d, ok := self.ruleDepth[req.GetRuleName()]; ok {
+		max = d
+	}
+	return max > 0 && req.GetDepth() > max
+}
+
 // Push 添加请求到队列，并发安全
 func (self *Matrix) Push(req *request.Request) {
+	// 超过最大深度
+	if self.tooDeep(req) {
+		logs.Log.Debug(" *     [超过最大深度 %v]: %v\n", req.GetDepth(), req.GetUrl())
+		return
+	}
+
+	// 遵守robots.txt协议，尚未下载时在后台下载，完成后重新加入
+	if !self.ignoreRobots {
+		if !sdl.robotsReady(req) {
+			self.waitRobots(req)
+			return
+		}
+		if !sdl.robotsAllowed(req) {
+			logs.Log.Informational(" *     [robots.txt禁止抓取]: %v\n", req.GetUrl())
+			return
+		}
+	}
+
 	// 禁止并发，降低请求积存量
 	self.Lock()
 	defer self.Unlock()
@@ -84,7 +201,7 @@ func (self *Matrix) Push(req *request.Request) {
 
 	// 资源使用过多时等待，降低请求积存量
 	waited = false
-	for atomic.LoadInt32(&self.resCount) > sdl.avgRes() {
+	for atomic.LoadInt32(&self.resCount) > sdl.share(self) {
 		waited = true
 		time.Sleep(100 * time.Millisecond)
 	}
@@ -94,28 +211,93 @@ func (self *Matrix) Push(req *request.Request) {
 
 	// 不可重复下载的req
 	if !req.IsReloadable() {
-		// 已存在成功记录时退出
-		if self.hasHistory(req.Unique()) {
+		// 已存在成功记录（且未到重新抓取时间）时退出
+		has, rec := self.hasHistory(req)
+		if has {
 			return
 		}
+		// 到期重新抓取时以条件请求校验内容是否修改
+		if rec != nil {
+			setConditional(req, rec)
+		}
 		// 添加到临时记录
 		self.insertTempHistory(req.Unique())
 	}
 
+	// 延迟调度的请求放入延迟队列，到期后再加入调度队列
+	if req.Delay > 0 {
+		if due := time.Now().Add(req.Delay); due.After(req.NotBefore) {
+			req.NotBefore = due
+		}
+		req.Delay = 0
+	}
+	if req.NotBefore.After(time.Now()) {
+		heap.Push(&self.delayed, &delayItem{due: req.NotBefore, req: req})
+	} else {
+		// 添加请求到队列
+		self.enqueue(req)
+	}
+	self.putFrontier(req)
+
+	// 大致限制加入队列的请求量，并发情况下应该会比maxPage多
+	atomic.AddInt64(&self.maxPage, 1)
+}
+
+// 在后台下载请求所属主机的robots.txt，完成后重新加入该请求
+func (self *Matrix) waitRobots(req *request.Request) {
+	atomic.AddInt32(&self.robotsWaiting, 1)
+	go func() {
+		defer atomic.AddInt32(&self.robotsWaiting, -1)
+		sdl.robotsAllowed(req)
+		self.Push(req)
+	}()
+}
+
+// 按优先级将请求加入队列，须持有锁
+func (self *Matrix) enqueue(req *request.Request) {
 	var priority = req.GetPriority()
 
 	// 初始化该蜘蛛下该优先级队列
 	if _, found := self.reqs[priority]; !found {
 		self.priorities = append(self.priorities, priority)
 		sort.Ints(self.priorities) // 从小到大排序
-		self.reqs[priority] = []*request.Request{}
+		self.reqs[priority] = newReqQueue()
 	}
 
-	// 添加请求到队列
-	self.reqs[priority] = append(self.reqs[priority], req)
+	self.reqs[priority].push(req)
+	atomic.AddInt32(&self.waiting, 1)
+}
 
-	// 大致限制加入队列的请求量，并发情况下应该会比maxPage多
-	atomic.AddInt64(&self.maxPage, 1)
+// Requeue 将处理中的请求重新加入队列，不做去重等检查，如因登录失效而失败的请求
+func (self *Matrix) Requeue(req *request.Request) {
+	self.Lock()
+	defer self.Unlock()
+	self.enqueue(req)
+}
+
+// SetHold 暂停或恢复该Spider请求的调度，暂停期间Pull返回nil且不会结束任务，
+// 可嵌套调用，须与SetHold(false)成对使用
+func (self *Matrix) SetHold(hold bool) {
+	if hold {
+		atomic.AddInt32(&self.hold, 1)
+	} else {
+		atomic.AddInt32(&self.hold, -1)
+	}
+}
+
+// 将请求放入延迟队列，到期后再加入调度队列
+func (self *Matrix) delay(req *request.Request, due time.Time) {
+	self.Lock()
+	defer self.Unlock()
+	heap.Push(&self.delayed, &delayItem{due: due, req: req})
+}
+
+// 将已到期的延迟请求移入调度队列，须持有锁
+func (self *Matrix) promote() {
+	now := time.Now()
+	for len(self.delayed) > 0 && !self.delayed[0].due.After(now) {
+		self.enqueue(heap.Pop(&self.delayed).(*delayItem).req)
+	}
 }
 
 // Pull 从队列取出请求，不存在时返回nil，并发安全
@@ -125,19 +307,57 @@ func (self *Matrix) Pull() (req *request.Request) {
 	if !sdl.checkStatus(status.RUN) {
 		return
 	}
+	// 暂停调度中
+	if atomic.LoadInt32(&self.hold) > 0 {
+		return
+	}
+	// 超过加权公平分配的资源量
+	if atomic.LoadInt32(&self.resCount) >= sdl.share(self) {
+		return
+	}
+	// 自适应并发控制
+	if self.auto != nil && !self.auto.allow(int(atomic.LoadInt32(&self.resCount))) {
+		return
+	}
+	self.promote()
 	// 按优先级从高到低取出请求
-	for i := len(self.reqs) - 1; i >= 0; i-- {
-		idx := self.priorities[i]
-		if len(self.reqs[idx]) > 0 {
-			req = self.reqs[idx][0]
-			self.reqs[idx] = self.reqs[idx][1:]
+	for i := len(self.priorities) - 1; i >= 0; i-- {
+		q := self.reqs[self.priorities[i]]
+		if q.len() == 0 {
+			continue
+		}
+		var key string
+		if sdl.throttle.enabled() {
+			// 取出第一个所属主机未被限速的请求
+			req = q.pop(func(host string) bool {
+				key = sdl.throttle.key(host)
+				return sdl.throttle.acquire(key)
+			})
+		} else {
+			req = q.pop(nil)
+		}
+		if req == nil {
+			continue
+		}
+		if key != "" {
+			if self.throttled == nil {
+				self.throttled = make(map[*request.Request][]string)
+			}
+			self.throttled[req] = append(self.throttled[req], key)
+		}
+		atomic.AddInt32(&self.waiting, -1)
+		// 规则中指定了代理时保留，不再自动分配
+		if !req.HasOwnProxy() {
 			if sdl.useProxy {
 				req.SetProxy(sdl.proxy.GetOne(req.GetUrl()))
 			} else {
 				req.SetProxy("")
 			}
-			return
 		}
+		if self.auto != nil {
+			self.auto.start()
+		}
+		return
 	}
 	return
 }
@@ -155,20 +375,56 @@ func (self *Matrix) Free() {
 	atomic.AddInt32(&self.resCount, -1)
 }
 
+// Done 请求处理完毕，释放其所属主机的并发名额，每次取出对应一次Done
+func (self *Matrix) Done(req *request.Request) {
+	self.Lock()
+	keys := self.throttled[req]
+	if len(keys) == 0 {
+		self.Unlock()
+		return
+	}
+	key := keys[0]
+	if len(keys) == 1 {
+		delete(self.throttled, req)
+	} else {
+		self.throttled[req] = keys[1:]
+	}
+	self.Unlock()
+	sdl.throttle.release(key)
+}
+
+// Feedback 反馈请求的下载耗时及错误类型（成功时为空），用于自适应并发控制
+func (self *Matrix) Feedback(latency time.Duration, kind string) {
+	if self.auto == nil {
+		return
+	}
+	self.auto.feedback(latency, kind)
+}
+
+// AutoThrottleStat 返回自适应并发的当前状态，未开启时ok为false
+func (self *Matrix) AutoThrottleStat() (stat AutoThrottleStat, ok bool) {
+	if self.auto == nil {
+		return
+	}
+	return self.auto.stat(self.spiderName), true
+}
+
 // DoHistory 返回是否作为新的失败请求被添加至队列尾部
 func (self *Matrix) DoHistory(req *request.Request, ok bool) bool {
 	if !req.IsReloadable() {
 		self.tempHistoryLock.Lock()
-		delete(self.tempHistory, req.Unique())
+		self.tempHistory.Del(req.Unique())
 		self.tempHistoryLock.Unlock()
 
 		if ok && req.NeedUrlUnique {
 			self.history.UpsertSuccess(req.Unique())
+			self.deleteFrontier(req)
 			return false
 		}
 	}
 
 	if ok {
+		self.deleteFrontier(req)
 		return false
 	}
 
@@ -182,9 +438,45 @@ func (self *Matrix) DoHistory(req *request.Request, ok bool) bool {
 	}
 	// 失败两次后，加入历史失败记录
 	self.history.UpsertFailure(req)
+	self.deleteFrontier(req)
+	if self.giveUp != nil {
+		self.giveUp(req)
+	}
 	return false
 }
 
+// DoFailure 按请求的重试策略处理失败请求，kind为错误类型，
+// 未设置重试策略时同DoHistory，返回是否为该请求本次运行中的首次失败
+func (self *Matrix) DoFailure(req *request.Request, kind string) bool {
+	policy := req.GetRetryPolicy()
+	if policy == nil {
+		return self.DoHistory(req, false)
+	}
+	rule := policy.Rule(kind)
+	req.FailTimes++
+	if req.FailTimes < rule.Attempts && !sdl.checkStatus(status.STOP) {
+		delay := rule.Delay(req.FailTimes)
+		logs.Log.Informational(" *     + 失败请求[%v]: [%v] %v后重试\n", kind, req.GetUrl(), delay)
+		self.delay(req, time.Now().Add(delay))
+		return req.FailTimes == 1
+	}
+
+	if !req.IsReloadable() {
+		self.tempHistoryLock.Lock()
+		self.tempHistory.Del(req.Unique())
+		self.tempHistoryLock.Unlock()
+	}
+	// 超过重试次数后，加入历史失败记录，下次运行时重新计数
+	first := req.FailTimes == 1
+	req.FailTimes = 0
+	self.history.UpsertFailure(req)
+	self.deleteFrontier(req)
+	if self.giveUp != nil {
+		self.giveUp(req)
+	}
+	return first
+}
+
 func (self *Matrix) CanStop() bool {
 	if sdl.checkStatus(status.STOP) {
 		return true
@@ -192,12 +484,18 @@ func (self *Matrix) CanStop() bool {
 	if self.maxPage >= 0 {
 		return true
 	}
-	if atomic.LoadInt32(&self.resCount) != 0 {
+	if atomic.LoadInt32(&self.hold) > 0 {
+		return false
+	}
+	if atomic.LoadInt32(&self.resCount) != 0 || atomic.LoadInt32(&self.robotsWaiting) != 0 {
 		return false
 	}
 	if self.Len() > 0 {
 		return false
 	}
+	if self.delayLen() > 0 {
+		return false
+	}
 
 	self.failureLock.Lock()
 	defer self.failureLock.Unlock()
@@ -234,6 +532,18 @@ func (self *Matrix) TryFlushFailure() {
 	}
 }
 
+// CloseFrontier 关闭持久化请求队列，
+// 主动终止任务时保留未完成的请求以便下次续爬，正常结束时删除。
+func (self *Matrix) CloseFrontier() {
+	if self.frontier == nil {
+		return
+	}
+	stopped := sdl.checkStatus(status.STOP)
+	if err := self.frontier.Close(!stopped); err != nil {
+		logs.Log.Error(" *     Fail  [关闭请求队列]: %v\n", err)
+	}
+}
+
 // Wait 等待处理中的请求完成
 func (self *Matrix) Wait() {
 	if sdl.checkStatus(status.STOP) {
@@ -250,28 +560,144 @@ func (self *Matrix) Len() int {
 	self.Lock()
 	defer self.Unlock()
 	var l int
-	for _, reqs := range self.reqs {
-		l += len(reqs)
+	for _, q := range self.reqs {
+		l += q.len()
 	}
 	return l
 }
 
-func (self *Matrix) hasHistory(reqUnique string) bool {
-	if self.history.HasSuccess(reqUnique) {
-		return true
-	}
+// 等待到期的延迟请求数
+func (self *Matrix) delayLen() int {
+	self.Lock()
+	defer self.Unlock()
+	return len(self.delayed)
+}
+
+// 是否已在队列中或已存在成功记录（增量抓取时未到重新抓取时间），
+// 到期重新抓取时同时返回上次抓取时的校验信息
+func (self *Matrix) hasHistory(req *request.Request) (bool, *history.Record) {
+	reqUnique := req.Unique()
 	self.tempHistoryLock.RLock()
-	has := self.tempHistory[reqUnique]
+	has := self.tempHistory.Has(reqUnique)
 	self.tempHistoryLock.RUnlock()
-	return has
+	if has {
+		return true, nil
+	}
+	if !self.history.HasSuccess(reqUnique) {
+		return false, nil
+	}
+	rec, due := self.revisitDue(reqUnique)
+	return !due, rec
+}
+
+// 增量抓取模式下，成功请求是否已到重新抓取时间，到期时返回上次抓取时的校验信息；
+// 无校验信息（如开启增量抓取前的成功记录）时以当前时刻为抓取时刻保存，一个间隔后再重新抓取
+func (self *Matrix) revisitDue(reqUnique string) (*history.Record, bool) {
+	if self.revisit <= 0 {
+		return nil, false
+	}
+	rec := self.history.GetRecord(reqUnique)
+	if rec == nil {
+		if err := self.history.PutRecord(reqUnique, &history.Record{Fetched: time.Now()}); err != nil {
+			logs.Log.Error(" *     Fail  [保存校验信息][%v]: %v\n", reqUnique, err)
+		}
+		return nil, false
+	}
+	if time.Since(rec.Fetched) < self.revisit {
+		return nil, false
+	}
+	return rec, true
+}
+
+// 按上次抓取时的校验信息设置条件请求头
+func setConditional(req *request.Request, rec *history.Record) {
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	if rec.ETag != "" {
+		req.SetHeader("If-None-Match", rec.ETag)
+	}
+	if rec.LastModified != "" {
+		req.SetHeader("If-Modified-Since", rec.LastModified)
+	}
+}
+
+// Revisited 增量抓取模式下，保存成功请求的校验信息，
+// 响应为304时沿用上次的内容摘要
+func (self *Matrix) Revisited(req *request.Request, resp *http.Response, hash string) {
+	if self.revisit <= 0 || resp == nil {
+		return
+	}
+	rec := &history.Record{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Hash:         hash,
+		Fetched:      time.Now(),
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		if old := self.history.GetRecord(req.Unique()); old != nil {
+			if rec.ETag == "" {
+				rec.ETag = old.ETag
+			}
+			if rec.LastModified == "" {
+				rec.LastModified = old.LastModified
+			}
+			rec.Hash = old.Hash
+		}
+	}
+	if err := self.history.PutRecord(req.Unique(), rec); err != nil {
+		logs.Log.Error(" *     Fail  [保存校验信息][%v]: %v\n", req.GetUrl(), err)
+	}
+}
+
+// CloseRevisit 关闭增量抓取的校验信息记录
+func (self *Matrix) CloseRevisit() {
+	if self.revisit <= 0 {
+		return
+	}
+	if err := self.history.CloseRevisit(); err != nil {
+		logs.Log.Error(" *     Fail  [关闭校验信息记录]: %v\n", err)
+	}
 }
 
 func (self *Matrix) insertTempHistory(reqUnique string) {
 	self.tempHistoryLock.Lock()
-	self.tempHistory[reqUnique] = true
+	self.tempHistory.Add(reqUnique)
 	self.tempHistoryLock.Unlock()
 }
 
+func (self *Matrix) openFrontier(spiderName, spiderSubName string) {
+	f, err := frontier.New(spiderName, spiderSubName)
+	if err != nil {
+		logs.Log.Error(" *     Fail  [打开请求队列]: %v\n", err)
+		return
+	}
+	reqs, err := f.Load()
+	if err != nil {
+		logs.Log.Error(" *     Fail  [读取请求队列]: %v\n", err)
+	}
+	self.frontier = f
+	self.resumable = reqs
+}
+
+func (self *Matrix) putFrontier(req *request.Request) {
+	if self.frontier == nil {
+		return
+	}
+	if err := self.frontier.Put(req); err != nil {
+		logs.Log.Error(" *     Fail  [保存请求][%v]: %v\n", req.GetUrl(), err)
+	}
+}
+
+func (self *Matrix) deleteFrontier(req *request.Request) {
+	if self.frontier == nil {
+		return
+	}
+	if err := self.frontier.Delete(req); err != nil {
+		logs.Log.Error(" *     Fail  [删除请求][%v]: %v\n", req.GetUrl(), err)
+	}
+}
+
 func (self *Matrix) setFailures(reqs map[string]*request.Request) {
 	self.failureLock.Lock()
 	defer self.failureLock.Unlock()
@@ -285,9 +711,9 @@ func (self *Matrix) setFailures(reqs map[string]*request.Request) {
 // func (self *Matrix) windup() {
 // 	self.Lock()
 
-// 	self.reqs = make(map[int][]*request.Request)
+// 	self.reqs = make(map[int]*reqQueue)
 // 	self.priorities = []int{}
-// 	self.tempHistory = make(map[string]bool)
+// 	self.tempHistory = history.NewPending()
 
 // 	self.failures = make(map[string]*request.Request)
 
@@ -0,0 +1,256 @@
+package scheduler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/molast/crawler-core/app/aid/history"
+	"github.com/molast/crawler-core/app/aid/robots"
+	"github.com/molast/crawler-core/app/downloader/request"
+)
+
+func TestMatrixDelay(t *testing.T) {
+	m := &Matrix{
+		maxPage: -100,
+		reqs:    make(map[int]*reqQueue),
+	}
+	sdl.matrices = []*Matrix{m}
+	defer func() { sdl.matrices = []*Matrix{} }()
+
+	m.Push(&request.Request{Url: "http://a.com/2", Reloadable: true, Delay: 50 * time.Millisecond})
+	m.Push(&request.Request{Url: "http://a.com/3", Reloadable: true, NotBefore: time.Now().Add(time.Hour)})
+	m.Push(&request.Request{Url: "http://a.com/1", Reloadable: true})
+
+	if req := m.Pull(); req == nil || req.Url != "http://a.com/1" {
+		t.Fatalf("Pull() = %v", req)
+	}
+	if req := m.Pull(); req != nil {
+		t.Fatalf("delayed request pulled early: %v", req.Url)
+	}
+	if m.CanStop() {
+		t.Fatal("should not stop with pending delayed requests")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if req := m.Pull(); req == nil || req.Url != "http://a.com/2" {
+		t.Fatalf("Pull() = %v", req)
+	}
+	if m.Pull() != nil || m.delayLen() != 1 {
+		t.Fatal("request with future NotBefore should stay delayed")
+	}
+}
+
+func TestMatrixMaxDepth(t *testing.T) {
+	m := &Matrix{
+		maxPage: -100,
+		reqs:    make(map[int]*reqQueue),
+	}
+	m.SetMaxDepth(2, map[string]int{"list": 5})
+	sdl.matrices = []*Matrix{m}
+	defer func() { sdl.matrices = []*Matrix{} }()
+
+	m.Push(&request.Request{Url: "http://a.com/1", Rule: "page", Reloadable: true, Depth: 2})
+	m.Push(&request.Request{Url: "http://a.com/2", Rule: "page", Reloadable: true, Depth: 3})
+	m.Push(&request.Request{Url: "http://a.com/3", Rule: "list", Reloadable: true, Depth: 5})
+	if n := m.Len(); n != 2 {
+		t.Fatalf("Len() = %d, want 2", n)
+	}
+}
+
+// 仅实现增量抓取所需方法的历史记录
+type revisitHistory struct {
+	history.Historier
+	success map[string]bool
+	records map[string]*history.Record
+}
+
+func (self *revisitHistory) HasSuccess(reqUnique string) bool {
+	return self.success[reqUnique]
+}
+
+func (self *revisitHistory) GetRecord(reqUnique string) *history.Record {
+	return self.records[reqUnique]
+}
+
+func (self *revisitHistory) PutRecord(reqUnique string, rec *history.Record) error {
+	self.records[reqUnique] = rec
+	return nil
+}
+
+func TestMatrixRevisit(t *testing.T) {
+	h := &revisitHistory{success: make(map[string]bool), records: make(map[string]*history.Record)}
+	m := &Matrix{
+		maxPage:     -100,
+		reqs:        make(map[int]*reqQueue),
+		tempHistory: history.NewPending(),
+		history:     h,
+		revisit:     time.Hour,
+	}
+	sdl.matrices = []*Matrix{m}
+	defer func() { sdl.matrices = []*Matrix{} }()
+
+	newReq := func(u string) *request.Request {
+		return &request.Request{Url: u, Header: make(http.Header)}
+	}
+	fresh, due, norec, unseen := newReq("http://a.com/fresh"), newReq("http://a.com/due"), newReq("http://a.com/norec"), newReq("http://a.com/new")
+	for _, req := range []*request.Request{fresh, due, norec} {
+		h.success[req.Unique()] = true
+	}
+	h.records[fresh.Unique()] = &history.Record{ETag: `"1"`, Fetched: time.Now()}
+	h.records[due.Unique()] = &history.Record{ETag: `"2"`, LastModified: "Mon, 02 Jan 2006 15:04:05 GMT", Hash: "h", Fetched: time.Now().Add(-2 * time.Hour)}
+
+	for _, req := range []*request.Request{fresh, due, norec, unseen} {
+		m.Push(req)
+	}
+	// 未到期及无校验信息的成功请求均不重新抓取，后者以当前时刻为抓取时刻
+	if n := m.Len(); n != 2 {
+		t.Fatalf("Len() = %d, want 2", n)
+	}
+	if rec := h.records[norec.Unique()]; rec == nil || time.Since(rec.Fetched) > time.Minute {
+		t.Fatalf("record = %+v", rec)
+	}
+	// 仅到期的请求带条件请求头
+	if due.Header.Get("If-None-Match") != `"2"` || due.Header.Get("If-Modified-Since") == "" {
+		t.Fatalf("header = %v", due.Header)
+	}
+	if fresh.Header.Get("If-None-Match") != "" || unseen.Header.Get("If-None-Match") != "" {
+		t.Fatal("conditional header set on request not due")
+	}
+
+	// 304时沿用上次的校验信息及内容摘要
+	m.Revisited(due, &http.Response{StatusCode: http.StatusNotModified, Header: http.Header{}}, "")
+	rec := h.records[due.Unique()]
+	if rec.ETag != `"2"` || rec.LastModified == "" || rec.Hash != "h" || time.Since(rec.Fetched) > time.Minute {
+		t.Fatalf("record = %+v", rec)
+	}
+}
+
+func TestFairShares(t *testing.T) {
+	cases := []struct {
+		total   int
+		demands []shareDemand
+		want    []int
+	}{
+		// 按权重分配
+		{12, []shareDemand{{weight: 2, demand: 100}, {weight: 1, demand: 100}}, []int{8, 4}},
+		// 空闲实例的资源借给繁忙实例
+		{12, []shareDemand{{weight: 1, demand: 100}, {weight: 1, demand: 2}}, []int{10, 2}},
+		// 最小及最大份额
+		{10, []shareDemand{{weight: 9, max: 3, demand: 100}, {weight: 1, min: 4, demand: 100}}, []int{3, 7}},
+		// 无需求时不分配
+		{10, []shareDemand{{demand: 0}, {demand: 5}}, []int{0, 5}},
+	}
+	for _, c := range cases {
+		got := fairShares(c.total, c.demands)
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("fairShares(%d, %+v) = %v, want %v", c.total, c.demands, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+func TestMatrixThrottle(t *testing.T) {
+	m := &Matrix{
+		maxPage: -100,
+		reqs:    make(map[int]*reqQueue),
+	}
+	sdl.matrices = []*Matrix{m}
+	old := sdl.throttle
+	sdl.throttle = newThrottle(0, 0, 1, false)
+	defer func() {
+		sdl.matrices = []*Matrix{}
+		sdl.throttle = old
+	}()
+
+	for _, u := range []string{"http://a.com/1", "http://a.com/2", "http://B.com/1"} {
+		m.Push(&request.Request{Url: u, Reloadable: true})
+	}
+	a1 := m.Pull()
+	if a1 == nil || a1.Url != "http://a.com/1" {
+		t.Fatalf("Pull() = %v", a1)
+	}
+	// a.com已达最大并发连接数，跳过其请求
+	if req := m.Pull(); req == nil || req.Url != "http://B.com/1" {
+		t.Fatalf("Pull() = %v", req)
+	}
+	if req := m.Pull(); req != nil {
+		t.Fatalf("throttled request pulled: %v", req.Url)
+	}
+	m.Done(a1)
+	if req := m.Pull(); req == nil || req.Url != "http://a.com/2" || m.Len() != 0 {
+		t.Fatalf("Pull() = %v", req)
+	}
+}
+
+func TestMatrixThrottleRequeue(t *testing.T) {
+	m := &Matrix{
+		maxPage: -100,
+		reqs:    make(map[int]*reqQueue),
+	}
+	sdl.matrices = []*Matrix{m}
+	old := sdl.throttle
+	sdl.throttle = newThrottle(0, 0, 2, false)
+	defer func() {
+		sdl.matrices = []*Matrix{}
+		sdl.throttle = old
+	}()
+
+	m.Push(&request.Request{Url: "http://a.com/1", Reloadable: true})
+	req := m.Pull()
+	if req == nil {
+		t.Fatal("Pull() = nil")
+	}
+	// 处理中的请求被重新加入队列，并在首次处理结束前再次取出
+	m.Requeue(req)
+	if again := m.Pull(); again != req {
+		t.Fatalf("Pull() = %v", again)
+	}
+	m.Done(req)
+	m.Done(req)
+	if active := sdl.throttle.bucket("a.com").active; active != 0 {
+		t.Fatalf("active = %d, want 0", active)
+	}
+	if len(m.throttled) != 0 {
+		t.Fatalf("throttled = %v", m.throttled)
+	}
+}
+
+func TestMatrixRobots(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte("User-agent: *\nDisallow: /admin\n"))
+	}))
+	defer srv.Close()
+
+	m := &Matrix{
+		maxPage: -100,
+		reqs:    make(map[int]*reqQueue),
+	}
+	sdl.matrices = []*Matrix{m}
+	old := sdl.robots
+	sdl.robots = robots.NewCache("crawler", time.Second, time.Hour, nil)
+	defer func() {
+		sdl.matrices = []*Matrix{}
+		sdl.robots = old
+	}()
+
+	// 下载robots.txt期间不阻塞Push，也不结束任务
+	m.Push(&request.Request{Url: srv.URL + "/index.html", Reloadable: true})
+	m.Push(&request.Request{Url: srv.URL + "/admin/users", Reloadable: true})
+	if m.Len() != 0 || m.CanStop() {
+		t.Fatalf("Len() = %d", m.Len())
+	}
+	close(release)
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&m.robotsWaiting) != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if req := m.Pull(); req == nil || req.Url != srv.URL+"/index.html" || m.Len() != 0 {
+		t.Fatalf("Pull() = %v, Len() = %d", req, m.Len())
+	}
+}
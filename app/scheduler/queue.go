@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/molast/crawler-core/app/downloader/request"
+)
+
+// 同一优先级的请求队列，按主机分组，
+// 主机限速时只需逐个主机而非逐个请求检查，各主机内及未限速时整体均按加入顺序调度
+type (
+	reqQueue struct {
+		hosts map[string][]queued // [主机]请求，按加入顺序
+		seq   uint64              // 下一个加入的请求的序号
+		n     int                 // 请求总数
+	}
+	queued struct {
+		req *request.Request
+		seq uint64
+	}
+)
+
+func newReqQueue() *reqQueue {
+	return &reqQueue{hosts: make(map[string][]queued)}
+}
+
+func (self *reqQueue) len() int {
+	return self.n
+}
+
+func (self *reqQueue) push(req *request.Request) {
+	host := hostOf(req.GetUrl())
+	self.hosts[host] = append(self.hosts[host], queued{req: req, seq: self.seq})
+	self.seq++
+	self.n++
+}
+
+// 按加入顺序依次检查各主机最早加入的请求，取出第一个ready返回true者，均不可调度时返回nil，
+// ready为nil时直接取出最早加入的请求
+func (self *reqQueue) pop(ready func(host string) bool) *request.Request {
+	if self.n == 0 {
+		return nil
+	}
+	hosts := make([]string, 0, len(self.hosts))
+	for host := range self.hosts {
+		hosts = append(hosts, host)
+	}
+	if ready == nil {
+		first := hosts[0]
+		for _, host := range hosts[1:] {
+			if self.hosts[host][0].seq < self.hosts[first][0].seq {
+				first = host
+			}
+		}
+		return self.take(first)
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		return self.hosts[hosts[i]][0].seq < self.hosts[hosts[j]][0].seq
+	})
+	for _, host := range hosts {
+		if ready(host) {
+			return self.take(host)
+		}
+	}
+	return nil
+}
+
+// 取出指定主机最早加入的请求
+func (self *reqQueue) take(host string) *request.Request {
+	q := self.hosts[host]
+	req := q[0].req
+	if len(q) == 1 {
+		delete(self.hosts, host)
+	} else {
+		q[0] = queued{}
+		self.hosts[host] = q[1:]
+	}
+	self.n--
+	return req
+}
+
+// 返回Url的主机名（小写，不含端口），用于按主机分组及限速
+func hostOf(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
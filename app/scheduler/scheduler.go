@@ -1,10 +1,15 @@
 package scheduler
 
 import (
+	"net/http"
 	"sync"
 	"time"
 
 	"github.com/molast/crawler-core/app/aid/proxy"
+	"github.com/molast/crawler-core/app/aid/robots"
+	"github.com/molast/crawler-core/app/downloader/request"
+	"github.com/molast/crawler-core/app/downloader/surfer"
+	"github.com/molast/crawler-core/config"
 	"github.com/molast/crawler-core/logs"
 	"github.com/molast/crawler-core/runtime/cache"
 	"github.com/molast/crawler-core/runtime/status"
@@ -12,14 +17,21 @@ import (
 
 // 调度器
 type scheduler struct {
-	status       int          // 运行状态
-	count        chan bool    // 总并发量计数
-	useProxy     bool         // 标记是否使用代理IP
-	proxy        *proxy.Proxy // 全局代理IP
-	matrices     []*Matrix    // Spider实例的请求矩阵列表
-	sync.RWMutex              // 全局读写锁
+	status       int           // 运行状态
+	count        chan bool     // 总并发量计数
+	useProxy     bool          // 标记是否使用代理IP
+	proxy        *proxy.Proxy  // 全局代理IP
+	matrices     []*Matrix     // Spider实例的请求矩阵列表
+	throttle     *throttle     // 主机级别的限速控制
+	robots       *robots.Cache // robots.txt缓存
+	sync.RWMutex               // 全局读写锁
 }
 
+const (
+	robotsTimeout = 10 * time.Second // 下载robots.txt的超时
+	robotsExpire  = 24 * time.Hour   // robots.txt的缓存有效期
+)
+
 // 定义全局调度
 var sdl = &scheduler{
 	status: status.RUN,
@@ -33,6 +45,14 @@ func Init() {
 	}
 	sdl.matrices = []*Matrix{}
 	sdl.count = make(chan bool, cache.Task.ThreadNum)
+	sdl.throttle = newThrottle(cache.Task.HostRate, cache.Task.HostBurst, cache.Task.HostConns, cache.Task.HostByIP)
+	sdl.robots = robots.NewCache(config.TAG, robotsTimeout, robotsExpire, robotsTransport{})
+	if sdl.throttle.enabled() {
+		logs.Log.Informational(" *     主机限速：每秒 %v 次请求（突发 %v 次），最大并发连接 %v 个\n", cache.Task.HostRate, sdl.throttle.burst, cache.Task.HostConns)
+	}
+	if cache.Task.AutoThrottle {
+		logs.Log.Informational(" *     自适应并发：并发量 %v ~ %v，请求间隔 %v ~ %v 毫秒\n", cache.Task.AutoMinThread, cache.Task.AutoMaxThread, cache.Task.AutoMinDelay, cache.Task.AutoMaxDelay)
+	}
 
 	if cache.Task.ProxySecond > 0 {
 		sdl.useProxy = true
@@ -85,13 +105,89 @@ func Stop() {
 	// println("scheduler$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$$")
 }
 
-// 每个spider实例分配到的平均资源量
-func (self *scheduler) avgRes() int32 {
-	avg := int32(cap(sdl.count) / len(sdl.matrices))
-	if avg == 0 {
-		avg = 1
+// AutoThrottleStats 返回各Spider实例自适应并发的当前状态
+func AutoThrottleStats() []AutoThrottleStat {
+	sdl.RLock()
+	defer sdl.RUnlock()
+	stats := make([]AutoThrottleStat, 0, len(sdl.matrices))
+	for _, matrix := range sdl.matrices {
+		if stat, ok := matrix.AutoThrottleStat(); ok {
+			stats = append(stats, stat)
+		}
+	}
+	return stats
+}
+
+// ProxyFeedback 反馈自动分配的代理IP的使用结果，kind为请求失败的错误类型，成功时为空
+func ProxyFeedback(req *request.Request, latency time.Duration, kind string) {
+	if !sdl.useProxy || req.HasOwnProxy() || req.GetProxy() == "" {
+		return
+	}
+	switch kind {
+	case request.RETRY_4XX, request.RETRY_5XX, request.RETRY_PANIC:
+		// 目标网站或规则的问题，与代理IP无关
+		return
+	}
+	sdl.proxy.Report(req.GetProxy(), kind == "", latency)
+}
+
+// ProxyBan 暂时停用请求自动分配的代理IP，如该代理IP已被目标网站封禁时，d<=0时按配置的停用时长
+func ProxyBan(req *request.Request, d time.Duration) {
+	if !sdl.useProxy || req.HasOwnProxy() || req.GetProxy() == "" {
+		return
+	}
+	if d <= 0 {
+		d = time.Duration(config.PROXY_BAN_SECOND) * time.Second
+	}
+	sdl.proxy.Ban(req.GetProxy(), d)
+}
+
+// HostBackoff 暂停请求所属主机的所有请求d时长，如被目标网站封禁时
+func HostBackoff(req *request.Request, d time.Duration) {
+	if sdl.throttle == nil {
+		return
+	}
+	sdl.throttle.backoff(sdl.throttle.key(hostOf(req.GetUrl())), d)
+	logs.Log.Informational(" *     主机暂停请求 %v: %v\n", d, req.GetUrl())
+}
+
+// ProxyStats 返回代理IP池中各代理IP的统计信息
+func ProxyStats() []proxy.Stat {
+	return sdl.proxy.Stats()
+}
+
+// 下载robots.txt所用的连接，与下载器一致地经代理IP池及Surf的共享连接池访问
+type robotsTransport struct{}
+
+func (robotsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var proxy string
+	if sdl.useProxy {
+		proxy = sdl.proxy.GetOne(req.URL.String())
+	}
+	t, err := surfer.Transport(req.URL.String(), proxy)
+	if err != nil {
+		return nil, err
+	}
+	return t.RoundTrip(req)
+}
+
+// 请求所属主机的robots.txt是否已下载，已下载时robotsAllowed不会阻塞
+func (self *scheduler) robotsReady(req *request.Request) bool {
+	return self.robots == nil || self.robots.Ready(req.GetUrl())
+}
+
+// 检查请求是否被robots.txt允许，并将其Crawl-delay应用于主机限速
+func (self *scheduler) robotsAllowed(req *request.Request) bool {
+	if self.robots == nil {
+		return true
+	}
+	if !self.robots.Allowed(req.GetUrl()) {
+		return false
+	}
+	if d := self.robots.CrawlDelay(req.GetUrl()); d > 0 {
+		self.throttle.setInterval(self.throttle.key(hostOf(req.GetUrl())), d)
 	}
-	return avg
+	return true
 }
 
 func (self *scheduler) checkStatus(s int) bool {
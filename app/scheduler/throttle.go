@@ -0,0 +1,165 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/molast/crawler-core/app/downloader/surfer"
+)
+
+// 按IP限速时解析主机的超时
+const resolveTimeout = 10 * time.Second
+
+// 主机级别的礼貌抓取控制（令牌桶限速 + 最大并发连接数）
+type (
+	throttle struct {
+		rate  float64                // 每个主机每秒允许的请求数，<=0为不限
+		burst int                    // 令牌桶容量，即允许的突发请求数
+		conns int                    // 每个主机最大并发连接数，<=0为不限
+		byIP  bool                   // 按解析后的IP而非主机名限速
+		hosts map[string]*hostBucket // [host或ip]令牌桶
+		ips   map[string]string      // [host]ip 解析结果，仅byIP时使用，解析完成前为host
+		delay int32                  // 是否存在设置了最小请求间隔或暂停请求的主机，原子操作
+		sync.Mutex
+	}
+	hostBucket struct {
+		tokens   float64       // 当前令牌数
+		last     time.Time     // 上次补充令牌的时刻
+		active   int           // 正在进行中的请求数
+		interval time.Duration // 最小请求间隔，如robots.txt中的Crawl-delay
+		started  time.Time     // 上次开始请求的时刻
+		paused   time.Time     // 暂停请求至该时刻，如被目标网站封禁后退避
+	}
+)
+
+func newThrottle(rate float64, burst, conns int, byIP bool) *throttle {
+	if rate > 0 && burst <= 0 {
+		burst = 1
+	}
+	return &throttle{
+		rate:  rate,
+		burst: burst,
+		conns: conns,
+		byIP:  byIP,
+		hosts: make(map[string]*hostBucket),
+		ips:   make(map[string]string),
+	}
+}
+
+// 是否启用了限速
+func (self *throttle) enabled() bool {
+	return self != nil && (self.rate > 0 || self.conns > 0 || atomic.LoadInt32(&self.delay) == 1)
+}
+
+// 设置指定主机的最小请求间隔
+func (self *throttle) setInterval(key string, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	self.Lock()
+	defer self.Unlock()
+	self.bucket(key).interval = d
+	atomic.StoreInt32(&self.delay, 1)
+}
+
+// 暂停指定主机的请求至d之后，已暂停更久时不变
+func (self *throttle) backoff(key string, d time.Duration) {
+	if self == nil || d <= 0 {
+		return
+	}
+	self.Lock()
+	defer self.Unlock()
+	b := self.bucket(key)
+	if until := time.Now().Add(d); until.After(b.paused) {
+		b.paused = until
+	}
+	atomic.StoreInt32(&self.delay, 1)
+}
+
+// 获取主机限速所用的键（主机名或IP），
+// 按IP限速时在后台经DNS缓存解析，解析完成前按主机名限速，不阻塞调度
+func (self *throttle) key(host string) string {
+	if !self.byIP || host == "" {
+		return host
+	}
+	self.Lock()
+	ip, ok := self.ips[host]
+	if !ok {
+		self.ips[host] = host
+		go self.resolve(host)
+	}
+	self.Unlock()
+	if !ok {
+		return host
+	}
+	return ip
+}
+
+// 解析主机的IP，失败时仍按主机名限速
+func (self *throttle) resolve(host string) {
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	ips, err := surfer.ResolveHost(ctx, host)
+	if err != nil || len(ips) == 0 {
+		return
+	}
+	self.Lock()
+	self.ips[host] = ips[0].String()
+	self.Unlock()
+}
+
+// 尝试为指定主机占用一个请求名额，返回是否成功
+func (self *throttle) acquire(key string) bool {
+	self.Lock()
+	defer self.Unlock()
+	b := self.bucket(key)
+	if self.conns > 0 && b.active >= self.conns {
+		return false
+	}
+	now := time.Now()
+	if now.Before(b.paused) {
+		return false
+	}
+	if b.interval > 0 && now.Sub(b.started) < b.interval {
+		return false
+	}
+	if self.rate > 0 {
+		b.tokens += now.Sub(b.last).Seconds() * self.rate
+		if b.tokens > float64(self.burst) {
+			b.tokens = float64(self.burst)
+		}
+		b.last = now
+		if b.tokens < 1 {
+			return false
+		}
+		b.tokens--
+	}
+	b.started = now
+	b.active++
+	return true
+}
+
+// 请求完成后释放指定主机的名额
+func (self *throttle) release(key string) {
+	self.Lock()
+	defer self.Unlock()
+	b, ok := self.hosts[key]
+	if !ok || b.active == 0 {
+		return
+	}
+	b.active--
+}
+
+func (self *throttle) bucket(key string) *hostBucket {
+	b, ok := self.hosts[key]
+	if !ok {
+		b = &hostBucket{
+			tokens: float64(self.burst),
+			last:   time.Now(),
+		}
+		self.hosts[key] = b
+	}
+	return b
+}
@@ -0,0 +1,119 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/molast/crawler-core/app/downloader/request"
+)
+
+func TestThrottleRate(t *testing.T) {
+	th := newThrottle(20, 2, 0, false)
+	key := th.key(hostOf("http://Example.com:8080/a?b=1"))
+	if key != "example.com" {
+		t.Fatalf("key: %q", key)
+	}
+	if !th.acquire(key) || !th.acquire(key) {
+		t.Fatal("burst should allow 2 requests")
+	}
+	if th.acquire(key) {
+		t.Fatal("third request should be throttled")
+	}
+	if !th.acquire("other.com") {
+		t.Fatal("other host should not be throttled")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if !th.acquire(key) {
+		t.Fatal("token should be refilled")
+	}
+}
+
+func TestThrottleConns(t *testing.T) {
+	th := newThrottle(0, 0, 1, false)
+	if !th.acquire("a.com") {
+		t.Fatal("first connection should be allowed")
+	}
+	if th.acquire("a.com") {
+		t.Fatal("second connection should be blocked")
+	}
+	th.release("a.com")
+	if !th.acquire("a.com") {
+		t.Fatal("connection should be released")
+	}
+	if newThrottle(0, 0, 0, false).enabled() {
+		t.Fatal("zero config should disable throttle")
+	}
+}
+
+func TestThrottleInterval(t *testing.T) {
+	th := newThrottle(0, 0, 0, false)
+	th.setInterval("a.com", 50*time.Millisecond)
+	if !th.enabled() {
+		t.Fatal("interval should enable throttle")
+	}
+	if !th.acquire("a.com") || th.acquire("a.com") {
+		t.Fatal("second request within interval should be blocked")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if !th.acquire("a.com") {
+		t.Fatal("request after interval should be allowed")
+	}
+}
+
+func TestAutoThrottle(t *testing.T) {
+	a := newAutoThrottle(1, 4, 0, time.Second)
+	if !a.allow(0) || a.allow(1) {
+		t.Fatal("should start with min concurrency")
+	}
+	for i := 0; i < 1+2+3; i++ {
+		a.feedback(10*time.Millisecond, "")
+	}
+	if a.conc != 4 {
+		t.Fatalf("conc = %d, want 4", a.conc)
+	}
+	a.feedback(time.Second, request.RETRY_429)
+	a.feedback(time.Second, request.RETRY_429)
+	if a.conc != 2 || a.delay != autoDelayUnit {
+		t.Fatalf("conc = %d, delay = %v after throttled", a.conc, a.delay)
+	}
+	a.start()
+	if a.allow(0) {
+		t.Fatal("request within delay should be blocked")
+	}
+	if stat := a.stat("test"); stat.ErrorRate <= 0 || stat.Concurrency != 2 {
+		t.Fatalf("stat = %+v", stat)
+	}
+}
+
+func TestThrottleBackoff(t *testing.T) {
+	th := newThrottle(0, 0, 0, false)
+	if th.enabled() {
+		t.Fatal("throttle should be disabled")
+	}
+	th.backoff("a.com", 50*time.Millisecond)
+	if !th.enabled() || th.acquire("a.com") {
+		t.Fatal("host should be paused")
+	}
+	if !th.acquire("b.com") {
+		t.Fatal("other host should not be paused")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if !th.acquire("a.com") {
+		t.Fatal("pause should expire")
+	}
+}
+
+func TestThrottleKeyByIP(t *testing.T) {
+	th := newThrottle(1, 1, 0, true)
+	// 解析完成前按主机名限速，不阻塞调用者
+	if key := th.key("localhost"); key != "localhost" {
+		t.Fatalf("key = %q", key)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for th.key("localhost") == "localhost" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if key := th.key("localhost"); key != "127.0.0.1" && key != "::1" {
+		t.Fatalf("key = %q", key)
+	}
+}
@@ -0,0 +1,292 @@
+package spider
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/molast/crawler-core/app/downloader/request"
+	"github.com/molast/crawler-core/app/downloader/surfer/agent"
+	"github.com/molast/crawler-core/app/scheduler"
+	"github.com/molast/crawler-core/logs"
+)
+
+type (
+	// Block 封禁（含验证码页面）的检测及应对方式，检测在解析前进行，
+	// 被封禁的响应不再解析，按以下设置应对后，该请求按request.RETRY_BLOCKED类型的失败重试
+	Block struct {
+		Detectors       []*BlockDetector // 检测规则，依次判定，任一规则命中即视为被封禁
+		RotateProxy     bool             // 是否停用该请求自动分配的代理IP，重试时将分配其他代理IP
+		ProxyBanTime    time.Duration    // 停用代理IP的时长，0为配置的PROXY_BAN_SECOND
+		RotateUserAgent bool             // 是否为该请求更换随机的User-Agent
+		HostBackoff     time.Duration    // 暂停该主机所有请求的时长，同一主机连续被封禁时倍增，0为不暂停
+		MaxBackoff      time.Duration    // 暂停时长的上限，0为10分钟
+		Solver          CaptchaSolver    // 验证码的处理方式，仅用于Captcha为true的检测规则
+		MaxSolves       int              // 同一主机连续被封禁时最多处理验证码的次数，超过后按失败重试，0为3
+	}
+	// BlockDetector 封禁的检测规则，所设置的条件中任一满足即命中
+	BlockDetector struct {
+		Name        string              // 规则名称，用于日志
+		Status      []int               // 视为被封禁的响应状态码，如403、429
+		UrlPattern  string              // 最终（重定向后）的Url匹配该正则表达式时视为被封禁，如"/verify|/captcha"
+		BodyPattern string              // 响应内容（仅文本内容，至多前BlockPeekSize字节）匹配该正则表达式时视为被封禁
+		Selector    string              // 页面（同上）中存在匹配该CSS选择器的元素时视为被封禁，如"#captcha"
+		Detect      func(*Context) bool // 自定义的判定，返回true时视为被封禁，读取响应内容后将无法再FileOutput
+		Captcha     bool                // 是否为可由Block.Solver处理的验证码页面
+		urlRe       *regexp.Regexp
+		bodyRe      *regexp.Regexp
+		once        sync.Once
+	}
+	// CaptchaSolver 验证码的处理方式，如调用打码平台或人工识别后提交答案
+	CaptchaSolver interface {
+		// Solve 处理ctx中的验证码页面，client使用该Spider的cookie容器，返回nil时视为已通过验证
+		Solve(ctx *Context, client *http.Client) error
+	}
+	// CaptchaSolverFunc 以函数实现CaptchaSolver，如单元测试中的桩
+	CaptchaSolverFunc func(ctx *Context, client *http.Client) error
+
+	// 封禁应对的运行状态，各Spider实例独立
+	blockState struct {
+		hosts  map[string]int // [主机]连续被封禁的次数
+		solved time.Time      // 最近一次通过验证的时刻
+		solve  sync.Mutex     // 同一时刻只处理一个验证码
+		sync.Mutex
+	}
+
+	// 检测封禁及登录失效时预读的响应内容，预读后放回响应流，不影响之后的解析及FileOutput
+	peekPage struct {
+		ctx  *Context
+		read bool
+		text []byte // 转码为UTF-8的内容，非文本内容时为nil
+		dom  *goquery.Document
+	}
+
+	// 预读部分与剩余响应流拼接后的Body
+	peekedBody struct {
+		io.Reader
+		io.Closer
+	}
+)
+
+// BlockPeekSize 检测封禁及登录失效时预读的响应内容的最大字节数
+var BlockPeekSize = 256 << 10
+
+// ErrBlocked 响应被判定为封禁页面
+var ErrBlocked = errors.New("被目标网站封禁")
+
+// Solve 实现CaptchaSolver接口
+func (self CaptchaSolverFunc) Solve(ctx *Context, client *http.Client) error {
+	return self(ctx, client)
+}
+
+// 编译检测规则中的正则表达式，有误时忽略该条件
+func (self *BlockDetector) compile() {
+	self.once.Do(func() {
+		var err error
+		if self.UrlPattern != "" {
+			if self.urlRe, err = regexp.Compile(self.UrlPattern); err != nil {
+				logs.Log.Error(" *     Fail  [block][%v] UrlPattern: %v\n", self.Name, err)
+			}
+		}
+		if self.BodyPattern != "" {
+			if self.bodyRe, err = regexp.Compile(self.BodyPattern); err != nil {
+				logs.Log.Error(" *     Fail  [block][%v] BodyPattern: %v\n", self.Name, err)
+			}
+		}
+	})
+}
+
+// 判断响应是否命中该规则
+func (self *BlockDetector) match(ctx *Context, page *peekPage) bool {
+	self.compile()
+	resp := ctx.Response
+	for _, code := range self.Status {
+		if resp.StatusCode == code {
+			return true
+		}
+	}
+	if self.urlRe != nil && resp.Request != nil && resp.Request.URL != nil &&
+		self.urlRe.MatchString(resp.Request.URL.String()) {
+		return true
+	}
+	if self.bodyRe != nil && self.bodyRe.Match(page.getText()) {
+		return true
+	}
+	if self.Selector != "" {
+		if dom := page.getDom(); dom != nil && dom.Find(self.Selector).Length() > 0 {
+			return true
+		}
+	}
+	return self.Detect != nil && self.Detect(ctx)
+}
+
+// 返回预读的文本内容，已解析时直接使用解析结果
+func (self *peekPage) getText() []byte {
+	if self.read {
+		return self.text
+	}
+	self.read = true
+	ctx := self.ctx
+	if ctx.text != nil {
+		self.text = ctx.text
+		return self.text
+	}
+	resp := ctx.Response
+	// 下载出错时没有可读取的响应内容
+	if resp.Body == nil {
+		return nil
+	}
+	head, err := io.ReadAll(io.LimitReader(resp.Body, int64(BlockPeekSize)))
+	resp.Body = &peekedBody{Reader: io.MultiReader(bytes.NewReader(head), resp.Body), Closer: resp.Body}
+	if err != nil || !textContent(resp.Header.Get("Content-Type"), head) {
+		return nil
+	}
+	name, _ := detectCharset(head, ctx.contentTypes()...)
+	if self.text, err = decodeCharset(head, name); err != nil {
+		self.text = head
+	}
+	return self.text
+}
+
+// 返回预读内容的Dom，非文本内容时返回nil
+func (self *peekPage) getDom() *goquery.Document {
+	if self.dom == nil && self.ctx.dom != nil {
+		self.dom = self.ctx.dom
+	}
+	if self.dom == nil {
+		if text := self.getText(); text != nil {
+			self.dom, _ = goquery.NewDocumentFromReader(bytes.NewReader(text))
+		}
+	}
+	return self.dom
+}
+
+// 创建封禁应对的运行状态
+func (self *Spider) blockInit() {
+	self.block = nil
+	if self.Block == nil || len(self.Block.Detectors) == 0 {
+		return
+	}
+	self.block = &blockState{hosts: make(map[string]int)}
+}
+
+// DetectBlock 按Block的检测规则判断响应是否为封禁页面，返回命中的规则，未被封禁时返回nil，
+// 并重置该主机连续被封禁的次数
+func (self *Spider) DetectBlock(ctx *Context) *BlockDetector {
+	if self.block == nil || ctx.Response == nil || ctx.Response.StatusCode == 0 {
+		return nil
+	}
+	page := &peekPage{ctx: ctx}
+	for _, d := range self.Block.Detectors {
+		if d.match(ctx, page) {
+			return d
+		}
+	}
+	host := blockHost(ctx.GetUrl())
+	self.block.Lock()
+	delete(self.block.hosts, host)
+	self.block.Unlock()
+	return nil
+}
+
+// HandleBlock 应对被封禁的请求：按设置停用代理IP、更换User-Agent、暂停该主机的请求，
+// 命中验证码规则时调用Solver处理，通过验证后立即重新加入队列，否则按request.RETRY_BLOCKED类型的失败处理；
+// start为该请求开始下载的时刻，此后已通过验证时不再重复处理，返回是否为该请求的首次失败
+func (self *Spider) HandleBlock(ctx *Context, detector *BlockDetector, start time.Time) bool {
+	var (
+		req  = ctx.Request
+		opts = self.Block
+		host = blockHost(req.GetUrl())
+	)
+	self.block.Lock()
+	self.block.hosts[host]++
+	times := self.block.hosts[host]
+	self.block.Unlock()
+	logs.Log.Warning(" *     Blocked  [%v][%v] 连续第%v次: %v\n", self.GetName(), detector.Name, times, req.GetUrl())
+
+	if opts.RotateProxy {
+		scheduler.ProxyBan(req, opts.ProxyBanTime)
+	}
+	if opts.RotateUserAgent {
+		if uas := agent.UserAgents["common"]; len(uas) > 0 {
+			req.SetHeader("User-Agent", uas[rand.Intn(len(uas))])
+		}
+	}
+	if opts.HostBackoff > 0 {
+		maxBackoff := opts.MaxBackoff
+		if maxBackoff <= 0 {
+			maxBackoff = 10 * time.Minute
+		}
+		d := opts.HostBackoff
+		for i := 1; i < times && d < maxBackoff; i++ {
+			d *= 2
+		}
+		if d > maxBackoff {
+			d = maxBackoff
+		}
+		scheduler.HostBackoff(req, d)
+	}
+
+	maxSolves := opts.MaxSolves
+	if maxSolves <= 0 {
+		maxSolves = 3
+	}
+	if detector.Captcha && opts.Solver != nil && times <= maxSolves && self.solveCaptcha(ctx, start) {
+		self.reqMatrix.Requeue(req)
+		return false
+	}
+	return self.DoFailure(req, request.RETRY_BLOCKED)
+}
+
+// 处理验证码，处理期间暂停该Spider的请求，返回是否已通过验证
+func (self *Spider) solveCaptcha(ctx *Context, start time.Time) bool {
+	self.block.solve.Lock()
+	defer self.block.solve.Unlock()
+	// 下载后已通过验证
+	if self.block.solved.After(start) {
+		return true
+	}
+	self.reqMatrix.SetHold(true)
+	defer self.reqMatrix.SetHold(false)
+
+	if err := self.callSolver(ctx); err != nil {
+		logs.Log.Error(" *     Fail  [captcha][%v]: %v\n", self.GetName(), err)
+		return false
+	}
+	self.block.solved = time.Now()
+	logs.Log.Informational(" *     [%v] 已通过验证码\n", self.GetName())
+	return true
+}
+
+// 执行验证码处理过程
+func (self *Spider) callSolver(ctx *Context) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = errors.New("验证码处理过程崩溃")
+			logs.Log.Error(" *     Panic  [captcha]: %v\n", p)
+		}
+	}()
+	client := &http.Client{Timeout: request.DefaultConnTimeout}
+	if self.cookieJar != nil {
+		client.Jar = self.cookieJar
+	}
+	return self.Block.Solver.Solve(ctx, client)
+}
+
+// 返回Url的主机名，用于统计连续被封禁的次数
+func blockHost(u string) string {
+	URL, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	return strings.ToLower(URL.Hostname())
+}
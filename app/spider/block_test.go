@@ -0,0 +1,148 @@
+package spider
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/molast/crawler-core/app/aid/cookies"
+	"github.com/molast/crawler-core/app/downloader/request"
+	"github.com/molast/crawler-core/app/scheduler"
+	"github.com/molast/crawler-core/config"
+	"github.com/molast/crawler-core/runtime/status"
+)
+
+func TestBlock(t *testing.T) {
+	var solves int
+	var fail bool
+	sp := &Spider{
+		Name: "block",
+		Block: &Block{
+			Detectors: []*BlockDetector{
+				{Name: "status", Status: []int{http.StatusForbidden}},
+				{Name: "verify", UrlPattern: `/verify\b`},
+				{Name: "captcha", Selector: "form#captcha", Captcha: true},
+				{Name: "body", BodyPattern: `访问过于频繁`},
+			},
+			RotateUserAgent: true,
+			Solver: CaptchaSolverFunc(func(ctx *Context, client *http.Client) error {
+				solves++
+				if fail {
+					return errors.New("wrong answer")
+				}
+				return nil
+			}),
+			MaxSolves: 2,
+		},
+	}
+	sp.status = status.RUN
+	sp.reqMatrix = scheduler.AddMatrix(sp.Name, "", math.MinInt64)
+	sp.cookieJar = cookies.New()
+	sp.blockInit()
+
+	newCtx := func(code int, finalUrl, body string) *Context {
+		URL, _ := url.Parse(finalUrl)
+		req := &request.Request{Url: "http://a.com/list", Header: make(http.Header), RetryPolicy: request.NewRetryPolicy()}
+		return &Context{spider: sp, Request: req, Response: &http.Response{
+			StatusCode: code,
+			Header:     http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+			Request:    &http.Request{URL: URL},
+		}}
+	}
+	detect := func(ctx *Context) string {
+		if d := sp.DetectBlock(ctx); d != nil {
+			return d.Name
+		}
+		return ""
+	}
+	cases := []struct {
+		ctx  *Context
+		want string
+	}{
+		{newCtx(403, "http://a.com/list", ""), "status"},
+		{newCtx(200, "http://a.com/verify?from=list", ""), "verify"},
+		{newCtx(200, "http://a.com/list", `<form id="captcha"></form>`), "captcha"},
+		{newCtx(200, "http://a.com/list", `<p>访问过于频繁</p>`), "body"},
+		{newCtx(200, "http://a.com/list", `<ul><li>ok</li></ul>`), ""},
+	}
+	for i, c := range cases {
+		if got := detect(c.ctx); got != c.want {
+			t.Fatalf("case %d: got %q, want %q", i, got, c.want)
+		}
+	}
+
+	// 未被封禁的响应仍可解析
+	ok := newCtx(200, "http://a.com/list", `<ul><li>ok</li></ul>`)
+	if detect(ok) != "" || ok.GetDom().Find("li").Text() != "ok" {
+		t.Fatal("body consumed by detection")
+	}
+
+	// 检测后仍可输出完整文件，二进制内容不做内容检测
+	defer func(dir string) { config.FILE_TEMP_DIR = dir }(config.FILE_TEMP_DIR)
+	config.FILE_TEMP_DIR = t.TempDir()
+	page := "<html><body>" + strings.Repeat("<p>内容</p>", BlockPeekSize/10) + "</body></html>"
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0xb7, 0xc3, 0xce, 0xca}, 1000)...)
+	for _, c := range []struct {
+		contentType string
+		body        []byte
+	}{
+		{"text/html; charset=utf-8", []byte(page)},
+		{"image/png", png},
+		{"", png},
+	} {
+		ctx := newCtx(200, "http://a.com/a.png", "")
+		ctx.Request.Rule = "file"
+		ctx.Response.Header.Set("Content-Type", c.contentType)
+		ctx.Response.Body = ioutil.NopCloser(bytes.NewReader(c.body))
+		if d := detect(ctx); d != "" {
+			t.Fatalf("%q: blocked by %v", c.contentType, d)
+		}
+		ctx.FileOutputWith(nil)
+		files := ctx.PullFiles()
+		if len(files) != 1 {
+			t.Fatalf("%q: files = %d", c.contentType, len(files))
+		}
+		b, _ := ioutil.ReadFile(files[0]["Path"].(string))
+		if !bytes.Equal(b, c.body) {
+			t.Fatalf("%q: saved %d bytes, want %d", c.contentType, len(b), len(c.body))
+		}
+	}
+
+	// 通过验证后立即重新加入队列
+	start := time.Now()
+	ctx := newCtx(200, "http://a.com/list", `<form id="captcha"></form>`)
+	d := sp.DetectBlock(ctx)
+	if sp.HandleBlock(ctx, d, start) || solves != 1 || sp.RequestLen() != 1 {
+		t.Fatalf("solves = %d, len = %d", solves, sp.RequestLen())
+	}
+	if ctx.Request.Header.Get("User-Agent") == "" {
+		t.Fatal("User-Agent not rotated")
+	}
+	// 同一次验证码只处理一次
+	sp.HandleBlock(ctx, d, start)
+	if solves != 1 || sp.RequestLen() != 2 {
+		t.Fatalf("solves = %d, len = %d", solves, sp.RequestLen())
+	}
+
+	// 处理失败时按失败延迟重试，未被封禁的响应重置连续被封禁的次数
+	detect(newCtx(200, "http://a.com/list", `<ul><li>ok</li></ul>`))
+	fail = true
+	for i := 1; i <= 2; i++ {
+		ctx = newCtx(200, "http://a.com/list", `<form id="captcha"></form>`)
+		if !sp.HandleBlock(ctx, d, time.Now()) || solves != i+1 || ctx.Request.FailTimes != 1 {
+			t.Fatalf("solves = %d, fails = %d", solves, ctx.Request.FailTimes)
+		}
+	}
+	// 超过MaxSolves后不再处理验证码
+	ctx = newCtx(200, "http://a.com/list", `<form id="captcha"></form>`)
+	if sp.HandleBlock(ctx, d, time.Now()); solves != 3 || sp.RequestLen() != 2 {
+		t.Fatalf("solves = %d, len = %d", solves, sp.RequestLen())
+	}
+}
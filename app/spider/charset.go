@@ -0,0 +1,226 @@
+package spider
+
+import (
+	"bytes"
+	"errors"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+
+	"github.com/molast/crawler-core/common/mahonia"
+)
+
+// 编码的判定依据
+const (
+	CHARSET_BOM    = "bom"    // 字节顺序标记
+	CHARSET_HEADER = "header" // 响应头（或请求头）Content-Type
+	CHARSET_META   = "meta"   // html的<meta charset>或http-equiv
+	CHARSET_XML    = "xml"    // xml声明
+	CHARSET_SNIFF  = "sniff"  // 按内容统计推测
+)
+
+var (
+	// 查找meta及xml声明的范围
+	charsetPrescan = 4096
+	// 统计推测时采样的字节数
+	charsetSniff = 64 << 10
+	// 统计推测时的候选编码，得分相同时靠前者优先
+	sniffCharsets = []string{"gbk", "windows-1252", "big5", "shift_jis", "euc-kr", "euc-jp"}
+	// 单字节的候选编码（windows-1252兼容latin1），西欧文字中的非ASCII字符多为前后均为ASCII的带变音符号的字母
+	singleByteCharsets = map[string]bool{"windows-1252": true}
+
+	boms = []struct {
+		bom  []byte
+		name string
+	}{
+		{[]byte{0xef, 0xbb, 0xbf}, "utf-8"},
+		{[]byte{0xfe, 0xff}, "utf-16be"},
+		{[]byte{0xff, 0xfe}, "utf-16le"},
+	}
+
+	metaCharsetRegexp = regexp.MustCompile(`(?i)<meta\s[^>]*?charset\s*=\s*["']?\s*([\w.:-]+)`)
+	xmlCharsetRegexp  = regexp.MustCompile(`^\s*<\?xml\s[^>]*?encoding\s*=\s*["']([\w.:-]+)["']`)
+)
+
+// 依次按BOM、Content-Type、<meta>、xml声明、统计推测判定内容的编码，返回规范化的编码名称及判定依据，
+// contentTypes为响应头及请求头的Content-Type，靠前者优先；
+// 仅推测文本内容（按响应头的Content-Type判断）的编码，非文本内容或推测结果不可信时返回空的编码名称
+func detectCharset(body []byte, contentTypes ...string) (name, source string) {
+	for _, b := range boms {
+		if bytes.HasPrefix(body, b.bom) {
+			return b.name, CHARSET_BOM
+		}
+	}
+	for _, contentType := range contentTypes {
+		if _, params, err := mime.ParseMediaType(contentType); err == nil {
+			if name = lookupCharset(params["charset"]); name != "" {
+				return name, CHARSET_HEADER
+			}
+		}
+	}
+	head := body
+	if len(head) > charsetPrescan {
+		head = head[:charsetPrescan]
+	}
+	if m := metaCharsetRegexp.FindSubmatch(head); m != nil {
+		if name = lookupCharset(string(m[1])); name != "" {
+			return name, CHARSET_META
+		}
+	}
+	if m := xmlCharsetRegexp.FindSubmatch(head); m != nil {
+		if name = lookupCharset(string(m[1])); name != "" {
+			return name, CHARSET_XML
+		}
+	}
+	if len(contentTypes) == 0 || !textContent(contentTypes[0], body) {
+		return "", ""
+	}
+	return sniffCharset(body), CHARSET_SNIFF
+}
+
+// 是否为文本内容：text/*、html、xml、json及javascript，contentType为空时按内容推测
+func textContent(contentType string, body []byte) bool {
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	mediatype, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediatype = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	switch {
+	case strings.HasPrefix(mediatype, "text/"),
+		strings.HasSuffix(mediatype, "/xml"), strings.HasSuffix(mediatype, "+xml"),
+		strings.HasSuffix(mediatype, "/json"), strings.HasSuffix(mediatype, "+json"),
+		strings.HasSuffix(mediatype, "javascript"):
+		return true
+	}
+	return false
+}
+
+// 返回编码标签的规范名称，不支持时返回空
+func lookupCharset(label string) string {
+	label = strings.ToLower(strings.Trim(strings.TrimSpace(label), `"'`))
+	if label == "" {
+		return ""
+	}
+	if e, name := charset.Lookup(label); e != nil {
+		return name
+	}
+	if mahonia.GetCharset(label) != nil {
+		return label
+	}
+	return ""
+}
+
+// 按内容统计推测编码：有效的UTF-8视为utf-8，
+// 否则使用mahonia逐一解码候选编码，选择无效字符及生僻字符最少者，
+// 最少者的得分仍超过非ASCII字节数的1/4时视为无法判定，返回空
+func sniffCharset(body []byte) string {
+	if len(body) > charsetSniff {
+		body = body[:charsetSniff]
+		// 去除末尾不完整的字符
+		for i := len(body) - 1; i >= 0 && i > len(body)-4; i-- {
+			if utf8.RuneStart(body[i]) {
+				body = body[:i]
+				break
+			}
+		}
+	}
+	if utf8.Valid(body) {
+		return "utf-8"
+	}
+	var high int
+	for _, b := range body {
+		if b >= 0x80 {
+			high++
+		}
+	}
+	best, bestScore := "", -1
+	for _, name := range sniffCharsets {
+		decode := mahonia.NewDecoder(name)
+		if decode == nil {
+			continue
+		}
+		score, prevHigh := 0, false
+		for p := body; len(p) > 0; {
+			c, size, status := decode(p)
+			if status == mahonia.NO_ROOM {
+				break
+			}
+			switch {
+			case status == mahonia.INVALID_CHAR:
+				score += 10
+			case c < 0x80:
+			case singleByteCharsets[name]:
+				if prevHigh || !latinRune(c) {
+					score++
+				}
+			case !commonRune(c):
+				score++
+			case name == "gbk" && size == 2 && (p[0] < 0xa1 || p[1] < 0xa1):
+				// GB2312以外的GBK扩展字符较少使用
+				score++
+			}
+			if size == 0 {
+				size = 1
+			}
+			prevHigh = p[0] >= 0x80
+			p = p[size:]
+		}
+		if bestScore < 0 || score < bestScore {
+			best, bestScore = name, score
+		}
+	}
+	if bestScore < 0 || bestScore*4 > high {
+		return ""
+	}
+	return best
+}
+
+// 是否为西欧文字中的常用字符：带变音符号的字母及常用符号
+func latinRune(c rune) bool {
+	switch {
+	case c >= 0xc0 && c <= 0xff && c != 0xd7 && c != 0xf7: // 带变音符号的字母
+	case c == 0xa0 || c == 0xab || c == 0xbb || c == 0xb0 || c == 0xb7 || c == 0xa9: // 空格、引号、度、间隔号、版权
+	case c == 0x152 || c == 0x153 || c == 0x160 || c == 0x161 || c == 0x178 || c == 0x17d || c == 0x17e: // Œœ Šš Ÿ Žž
+	case c >= 0x2013 && c <= 0x2026 || c == 0x20ac: // 破折号、弯引号、省略号、欧元符号
+	default:
+		return false
+	}
+	return true
+}
+
+// 是否为东亚文字中的常用字符
+func commonRune(c rune) bool {
+	switch {
+	case c >= 0x4e00 && c <= 0x9fff: // 中日韩统一表意文字
+	case c >= 0x3000 && c <= 0x30ff: // 标点、平假名、片假名
+	case c >= 0xac00 && c <= 0xd7af: // 韩文音节
+	case c >= 0xff00 && c <= 0xffef: // 全角字符
+	case c >= 0x2000 && c <= 0x206f: // 常用标点
+	default:
+		return false
+	}
+	return true
+}
+
+// 将内容由指定编码转为UTF-8，name为空时不转码
+func decodeCharset(body []byte, name string) ([]byte, error) {
+	if name == "" {
+		return body, nil
+	}
+	if name == "utf-8" {
+		return bytes.TrimPrefix(body, boms[0].bom), nil
+	}
+	if e, _ := charset.Lookup(name); e != nil {
+		return e.NewDecoder().Bytes(body)
+	}
+	if decode := mahonia.NewDecoder(name); decode != nil {
+		return []byte(decode.ConvertString(string(body))), nil
+	}
+	return nil, errors.New("不支持的编码 " + name)
+}
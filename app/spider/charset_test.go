@@ -0,0 +1,97 @@
+package spider
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/molast/crawler-core/common/mahonia"
+)
+
+func TestDetectCharset(t *testing.T) {
+	gbk := func(s string) []byte {
+		return []byte(mahonia.NewEncoder("gbk").ConvertString(s))
+	}
+	text := "中文网页的标题，测试编码识别"
+	cases := []struct {
+		body        []byte
+		contentType string
+		name        string
+		source      string
+	}{
+		{append([]byte{0xef, 0xbb, 0xbf}, text...), "text/html; charset=gbk", "utf-8", CHARSET_BOM},
+		{gbk(text), "text/html; charset=GB2312", "gbk", CHARSET_HEADER},
+		{gbk(`<html><head><meta charset="gbk"><title>` + text + `</title>`), "text/html", "gbk", CHARSET_META},
+		{gbk(`<meta http-equiv="Content-Type" content="text/html; charset=gb2312">` + text), "", "gbk", CHARSET_META},
+		{gbk(`<?xml version="1.0" encoding="GB18030"?><rss>` + text), "", "gb18030", CHARSET_XML},
+		{gbk(`<html><title>` + text + `</title>`), "", "gbk", CHARSET_SNIFF},
+		{[]byte(`<html><title>` + text + `</title>`), "", "utf-8", CHARSET_SNIFF},
+	}
+	for i, c := range cases {
+		name, source := detectCharset(c.body, c.contentType)
+		if name != c.name || source != c.source {
+			t.Fatalf("case %d: got %v (%v), want %v (%v)", i, name, source, c.name, c.source)
+		}
+		b, err := decodeCharset(c.body, name)
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if !strings.Contains(string(b), text) {
+			t.Fatalf("case %d: decoded %q", i, b)
+		}
+	}
+}
+
+func TestSniffCharset(t *testing.T) {
+	latin1 := func(s string) []byte {
+		return []byte(mahonia.NewEncoder("windows-1252").ConvertString(s))
+	}
+	gbk := func(s string) []byte {
+		return []byte(mahonia.NewEncoder("gbk").ConvertString(s))
+	}
+	french := "<p>Le développement économique de la région a été présenté à l'assemblée générale.</p>"
+	german := "<p>Die Größe der Städte wächst, während die Bevölkerung auf dem Land zurückgeht.</p>"
+	chinese := "<p>中文网页的标题，测试编码识别，以及更多的正文内容。</p>"
+
+	png := append([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), gbk(chinese)...)
+	random := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(random)
+	for i := range random {
+		// 去除控制字符，避免按内容推测为二进制
+		if random[i] < 0x20 {
+			random[i] += 0x20
+		}
+	}
+
+	cases := []struct {
+		body        []byte
+		contentType string
+		name        string
+		text        string
+	}{
+		{latin1(french), "text/html", "windows-1252", french},
+		{latin1(german), "text/html", "windows-1252", german},
+		{latin1(german), "", "windows-1252", german},
+		{gbk(chinese + german), "text/html", "gbk", chinese},
+		// 二进制内容不推测编码，内容不变
+		{png, "image/png", "", ""},
+		{png, "", "", ""},
+		{random, "application/octet-stream", "", ""},
+		// 推测结果不可信时不转码
+		{random, "text/plain", "", ""},
+	}
+	for i, c := range cases {
+		name, _ := detectCharset(c.body, c.contentType)
+		if name != c.name {
+			t.Fatalf("case %d: got %q, want %q", i, name, c.name)
+		}
+		b, err := decodeCharset(c.body, name)
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if name == "" && !bytes.Equal(b, c.body) || !strings.Contains(string(b), c.text) {
+			t.Fatalf("case %d: decoded %q", i, b)
+		}
+	}
+}
@@ -2,9 +2,9 @@ package spider
 
 import (
 	"bytes"
+	"crypto/md5"
+	"encoding/hex"
 	"io"
-	"io/ioutil"
-	"mime"
 	"net/http"
 	"path"
 	"strings"
@@ -13,7 +13,6 @@ import (
 	"unsafe"
 
 	"github.com/PuerkitoBio/goquery"
-	"golang.org/x/net/html/charset"
 
 	"github.com/molast/crawler-core/app/downloader/request"
 	"github.com/molast/crawler-core/app/pipeline/collector/data"
@@ -26,9 +25,10 @@ type Context struct {
 	Request  *request.Request  // 原始请求
 	Response *http.Response    // 响应流，其中URL拷贝自*request.Request
 	text     []byte            // 下载内容Body的字节流格式
+	charset  string            // 下载内容转码前的编码
 	dom      *goquery.Document // 下载内容Body为html时，可转换为Dom的对象
 	items    []data.DataCell   // 存放以文本形式输出的结果数据
-	files    []data.FileCell   // 存放欲直接输出的文件("Name": string; "Body": io.ReadCloser)
+	files    []data.FileCell   // 存放欲直接输出的文件("Name": string; "Path": string; "Size": int64)
 	err      error             // 错误标记
 	sync.Mutex
 }
@@ -55,7 +55,9 @@ func GetContext(sp *Spider, req *request.Request) *Context {
 
 func PutContext(ctx *Context) {
 	if ctx.Response != nil {
-		ctx.Response.Body.Close() // too many open files bug remove
+		if ctx.Response.Body != nil {
+			ctx.Response.Body.Close() // too many open files bug remove
+		}
 		ctx.Response = nil
 	}
 	ctx.items = ctx.items[:0]
@@ -63,6 +65,7 @@ func PutContext(ctx *Context) {
 	ctx.spider = nil
 	ctx.Request = nil
 	ctx.text = nil
+	ctx.charset = ""
 	ctx.dom = nil
 	ctx.err = nil
 	contextPool.Put(ctx)
@@ -102,6 +105,16 @@ func (self *Context) AddQueue(req *request.Request) *Context {
 		SetEnableCookie(self.spider.GetEnableCookie()).
 		Prepare()
 
+	if req.GetRetryPolicy() == nil {
+		req.SetRetryPolicy(self.spider.RetryPolicy)
+	}
+	if req.GetFingerprint() == nil {
+		req.SetFingerprint(self.spider.Fingerprint)
+	}
+	if self.Request != nil {
+		req.SetDepth(self.Request.GetDepth() + 1)
+	}
+
 	if err != nil {
 		logs.Log.Error(err.Error())
 		return self
@@ -117,6 +130,8 @@ func (self *Context) AddQueue(req *request.Request) *Context {
 }
 
 // JsAddQueue 用于动态规则添加请求。
+// 其中Delay以毫秒为单位；NotBefore可为毫秒时间戳（如Date.now()、date.getTime()）
+// 或"2006-01-02 15:04:05"（本地时间）、RFC3339格式的字符串。
 func (self *Context) JsAddQueue(jreq map[string]interface{}) *Context {
 	// 若已主动终止任务，则崩溃爬虫协程
 	self.spider.tryPanic()
@@ -159,6 +174,14 @@ func (self *Context) JsAddQueue(jreq map[string]interface{}) *Context {
 	if t, ok := jreq["Priority"].(int64); ok {
 		req.Priority = int(t)
 	}
+	// Delay同Pausetime以毫秒为单位
+	if t, ok := jsNumber(jreq["Delay"]); ok {
+		req.Delay = time.Duration(t) * time.Millisecond
+	}
+	if t, ok := jsTime(jreq["NotBefore"]); ok {
+		req.NotBefore = t
+	}
+	req.Proxy, _ = jreq["Proxy"].(string)
 	if t, ok := jreq["DownloaderID"].(int64); ok {
 		req.DownloaderID = int(t)
 	}
@@ -171,6 +194,16 @@ func (self *Context) JsAddQueue(jreq map[string]interface{}) *Context {
 		SetEnableCookie(self.spider.GetEnableCookie()).
 		Prepare()
 
+	if req.GetRetryPolicy() == nil {
+		req.SetRetryPolicy(self.spider.RetryPolicy)
+	}
+	if req.GetFingerprint() == nil {
+		req.SetFingerprint(self.spider.Fingerprint)
+	}
+	if self.Request != nil {
+		req.SetDepth(self.Request.GetDepth() + 1)
+	}
+
 	if err != nil {
 		logs.Log.Error(err.Error())
 		return self
@@ -184,6 +217,38 @@ func (self *Context) JsAddQueue(jreq map[string]interface{}) *Context {
 	return self
 }
 
+// 将JS数值（整数或浮点数）转为int64
+func jsNumber(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	case float64:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+// 将JS的毫秒时间戳或时间字符串转为time.Time
+func jsTime(v interface{}) (time.Time, bool) {
+	if ms, ok := jsNumber(v); ok {
+		return time.Unix(0, ms*int64(time.Millisecond)), true
+	}
+	s, ok := v.(string)
+	if !ok || s == "" {
+		return time.Time{}, false
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.Local); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true
+	}
+	logs.Log.Error(" *     Fail  [NotBefore无效]: %v\n", s)
+	return time.Time{}, false
+}
+
 // Output 输出文本结果。
 // item类型为map[int]interface{}时，根据ruleName现有的ItemFields字段进行输出，
 // item类型为map[string]interface{}时，ruleName不存在的ItemFields字段将被自动添加，
@@ -213,20 +278,38 @@ func (self *Context) Output(item interface{}, ruleName ...string) {
 	if self.spider.NotDefaultField {
 		self.items = append(self.items, data.GetDataCell(_ruleName, _item, "", "", ""))
 	} else {
-		self.items = append(self.items, data.GetDataCell(_ruleName, _item, self.GetUrl(), self.GetReferer(), time.Now().Format("2006-01-02 15:04:05")))
+		cell := data.GetDataCell(_ruleName, _item, self.GetUrl(), self.GetReferer(), time.Now().Format("2006-01-02 15:04:05"))
+		if self.spider.OutDepth {
+			cell.SetDepth(self.GetDepth())
+		}
+		self.items = append(self.items, cell)
 	}
 	self.Unlock()
 }
 
-// FileOutput 输出文件。
+// FileOutput 输出文件，按Spider.FileOptions写入临时文件。
 // nameOrExt指定文件名或仅扩展名，为空时默认保持原文件名（包括扩展名）不变。
 func (self *Context) FileOutput(nameOrExt ...string) {
-	// 读取完整文件流
-	bytes, err := io.ReadAll(self.Response.Body)
-	self.Response.Body.Close()
+	self.FileOutputWith(self.spider.FileOptions, nameOrExt...)
+}
+
+// FileOutputWith 按指定选项输出文件，opts为nil时不限大小、不计算校验和、不续传。
+// 文件内容直接写入FILE_TEMP_DIR下的临时文件，不在内存中缓存。
+func (self *Context) FileOutputWith(opts *FileOptions, nameOrExt ...string) {
+	if opts == nil {
+		opts = &FileOptions{}
+	}
+	tmpName, size, checksum, err := self.saveBody(opts)
+	if err == ErrFileTooLarge {
+		logs.Log.Error(" *     Fail  [文件下载][%v]: %v（%v 字节）\n", self.GetUrl(), err, opts.MaxSize)
+		return
+	}
+	if _, ok := err.(*TransferError); ok {
+		// 下载中断，由crawler按下载错误重试（续传时保留已下载部分）
+		panic(err)
+	}
 	if err != nil {
 		panic(err.Error())
-		return
 	}
 
 	// 智能设置完整文件名
@@ -254,7 +337,7 @@ func (self *Context) FileOutput(nameOrExt ...string) {
 
 	// 保存到文件临时队列
 	self.Lock()
-	self.files = append(self.files, data.GetFileCell(self.GetRuleName(), baseName+ext, bytes))
+	self.files = append(self.files, data.GetFileCell(self.GetRuleName(), baseName+ext, tmpName, size, checksum))
 	self.Unlock()
 }
 
@@ -530,6 +613,23 @@ func (self *Context) GetUrl() string {
 	return self.Request.Url
 }
 
+// ContentHash 返回已读取的响应内容的md5，未读取内容时为空
+func (self *Context) ContentHash() string {
+	if len(self.text) == 0 {
+		return ""
+	}
+	block := md5.Sum(self.text)
+	return hex.EncodeToString(block[:])
+}
+
+// GetDepth 获取当前请求与根请求的距离，Root中为0
+func (self *Context) GetDepth() int {
+	if self.Request == nil {
+		return 0
+	}
+	return self.Request.GetDepth()
+}
+
 func (self *Context) GetMethod() string {
 	return self.Request.GetMethod()
 }
@@ -557,6 +657,29 @@ func (self *Context) GetCookie() string {
 	return self.Response.Header.Get("Set-Cookie")
 }
 
+// GetCookies 获取当前Spider实例中属于domain（域名或url）及其子域名的cookie，domain为空时返回全部。
+func (self *Context) GetCookies(domain string) []*http.Cookie {
+	if jar := self.spider.GetCookieJar(); jar != nil {
+		return jar.Get(domain)
+	}
+	return nil
+}
+
+// SetCookies 为domain（域名或url）设置cookie，如以已有的登录状态开始采集，须Spider.EnableCookie为true方可生效。
+func (self *Context) SetCookies(domain string, cookies ...*http.Cookie) {
+	if jar := self.spider.GetCookieJar(); jar != nil {
+		jar.Set(domain, cookies...)
+	}
+}
+
+// ClearCookies 清除属于domain（域名或url）及其子域名的cookie，domain为空时清除全部，返回清除的数量。
+func (self *Context) ClearCookies(domain string) int {
+	if jar := self.spider.GetCookieJar(); jar != nil {
+		return jar.Clear(domain)
+	}
+	return 0
+}
+
 // GetDom GetHtmlParser returns goquery object binded to target crawl result.
 func (self *Context) GetDom() *goquery.Document {
 	if self.dom == nil {
@@ -573,6 +696,14 @@ func (self *Context) GetText() string {
 	return util.Bytes2String(self.text)
 }
 
+// GetCharset 返回下载内容转码为UTF-8前的编码，如"gbk"，非文本内容或无法判定编码（未转码）时为空
+func (self *Context) GetCharset() string {
+	if self.text == nil {
+		self.initText()
+	}
+	return self.charset
+}
+
 // GetBytes returns plain bytes crawled.
 func (self *Context) GetBytes() []byte {
 	if self.text == nil {
@@ -611,63 +742,30 @@ func (self *Context) initDom() *goquery.Document {
 }
 
 // GetBodyStr returns plain string crawled.
+// 按BOM、Content-Type、<meta>、xml声明、统计推测的顺序判定编码，并转码为UTF-8
 func (self *Context) initText() {
-	var err error
-
-	// 采用surf内核下载时，尝试自动转码
-	if self.Request.DownloaderID == request.SURF_ID {
-		var contentType, pageEncode string
-		// 优先从响应头读取编码类型
-		contentType = self.Response.Header.Get("Content-Type")
-		if _, params, err := mime.ParseMediaType(contentType); err == nil {
-			if cs, ok := params["charset"]; ok {
-				pageEncode = strings.ToLower(strings.TrimSpace(cs))
-			}
-		}
-		// 响应头未指定编码类型时，从请求头读取
-		if len(pageEncode) == 0 {
-			contentType = self.Request.Header.Get("Content-Type")
-			if _, params, err := mime.ParseMediaType(contentType); err == nil {
-				if cs, ok := params["charset"]; ok {
-					pageEncode = strings.ToLower(strings.TrimSpace(cs))
-				}
-			}
-		}
-		switch pageEncode {
-		// 不做转码处理
-		case "utf8", "utf-8", "unicode-1-1-utf-8", "":
-		default:
-			// 指定了编码类型，但不是utf8时，自动转码为utf8
-			// get converter to utf-8
-			// Charset auto determine. Use golang.org/x/net/html/charset. Get response body and change it to utf-8
-			var destReader io.Reader
-			if len(pageEncode) == 0 {
-				destReader, err = charset.NewReader(self.Response.Body, "")
-			} else {
-				destReader, err = charset.NewReaderLabel(pageEncode, self.Response.Body)
-			}
-			self.text = []byte("")
-			if err == nil {
-				self.text, err = ioutil.ReadAll(destReader)
-				if err == nil {
-					self.Response.Body.Close()
-					return
-				} else {
-					logs.Log.Warning(" *     [convert][%v]: %v (ignore transcoding)\n", self.GetUrl(), err)
-				}
-			} else {
-				logs.Log.Warning(" *     [convert][%v]: %v (ignore transcoding)\n", self.GetUrl(), err)
-			}
-		}
-	}
-
-	// 不做转码处理
-	self.text, err = io.ReadAll(self.Response.Body)
+	body, err := io.ReadAll(self.Response.Body)
 	self.Response.Body.Close()
 	if err != nil {
 		panic(err.Error())
-		return
 	}
+
+	self.charset, _ = detectCharset(body, self.contentTypes()...)
+
+	self.text, err = decodeCharset(body, self.charset)
+	if err != nil {
+		logs.Log.Warning(" *     [convert][%v]: %v (ignore transcoding)\n", self.GetUrl(), err)
+		self.text = body
+	}
+}
+
+// 返回响应头及请求头的Content-Type，响应头未指定编码类型时，从请求头读取
+func (self *Context) contentTypes() []string {
+	var contentTypes = []string{self.Response.Header.Get("Content-Type")}
+	if self.Request != nil {
+		contentTypes = append(contentTypes, self.Request.Header.Get("Content-Type"))
+	}
+	return contentTypes
 }
 
 /**
@@ -0,0 +1,34 @@
+package spider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+func TestJsRequestFields(t *testing.T) {
+	vm := otto.New()
+	v, err := vm.Run(`({Delay: 1500, Half: 2.5, At: new Date(2020, 0, 2, 3, 4, 5).getTime(), Str: "2020-01-02 03:04:05"})`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported, _ := v.Export()
+	jreq := exported.(map[string]interface{})
+
+	if d, ok := jsNumber(jreq["Delay"]); !ok || time.Duration(d)*time.Millisecond != 1500*time.Millisecond {
+		t.Fatalf("Delay = %v %v", d, ok)
+	}
+	if d, ok := jsNumber(jreq["Half"]); !ok || d != 2 {
+		t.Fatalf("Half = %v %v", d, ok)
+	}
+	want := time.Date(2020, 1, 2, 3, 4, 5, 0, time.Local)
+	for _, k := range []string{"At", "Str"} {
+		if at, ok := jsTime(jreq[k]); !ok || !at.Equal(want) {
+			t.Fatalf("%v = %v %v", k, at, ok)
+		}
+	}
+	if _, ok := jsTime(jreq["Missing"]); ok {
+		t.Fatal("missing NotBefore accepted")
+	}
+}
@@ -0,0 +1,205 @@
+package spider
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/molast/crawler-core/app/downloader/request"
+	"github.com/molast/crawler-core/config"
+)
+
+// FileOptions 文件输出的选项
+type FileOptions struct {
+	MaxSize  int64  // 文件的最大字节数，超过时放弃输出，0为不限
+	Checksum string // 校验和算法：md5、sha1、sha256，为空时不计算
+	Resume   bool   // 下载中断时保留已下载的部分，重试该请求时以Range请求续传
+}
+
+// ErrFileTooLarge 文件超过FileOptions.MaxSize
+var ErrFileTooLarge = errors.New("文件超过最大字节数限制")
+
+// TransferError 文件输出时响应流读取中断（如超时、连接断开），
+// FileOutput以该错误崩溃，由crawler按下载错误分类及重试，而不视为解析崩溃
+type TransferError struct {
+	Err error
+}
+
+func (self *TransferError) Error() string {
+	return "文件下载中断: " + self.Err.Error()
+}
+
+func (self *TransferError) Unwrap() error {
+	return self.Err
+}
+
+// 记录读取响应流时的错误，以区别于写入临时文件的错误
+type bodyReader struct {
+	r   io.Reader
+	err error
+}
+
+func (self *bodyReader) Read(p []byte) (int, error) {
+	n, err := self.r.Read(p)
+	if err != nil && err != io.EOF {
+		self.err = err
+	}
+	return n, err
+}
+
+// 续传时保存已下载部分的文件名
+const PART_EXT = ".part"
+
+// 返回校验和算法，为空或不支持时返回nil
+func (self *FileOptions) newHash() hash.Hash {
+	switch strings.ToLower(self.Checksum) {
+	case "md5":
+		return md5.New()
+	case "sha1":
+		return sha1.New()
+	case "sha256":
+		return sha256.New()
+	}
+	return nil
+}
+
+// 将响应流写入FILE_TEMP_DIR下的临时文件，返回临时文件名、文件大小及校验和
+func (self *Context) saveBody(opts *FileOptions) (tmpName string, size int64, checksum string, err error) {
+	resp := self.Response
+	defer resp.Body.Close()
+	if opts.MaxSize > 0 && resp.StatusCode != http.StatusPartialContent && resp.ContentLength > opts.MaxSize {
+		return "", 0, "", ErrFileTooLarge
+	}
+	if err = os.MkdirAll(config.FILE_TEMP_DIR, 0777); err != nil {
+		return
+	}
+
+	var f *os.File
+	var offset int64
+	part := partName(self.Request)
+	if opts.Resume {
+		// 服务器从已下载部分的末尾继续发送时，追加写入
+		if start, ok := contentRangeStart(resp); ok {
+			if info, e := os.Stat(part); e != nil || info.Size() != start {
+				// 续传位置与已下载部分不一致，重试时重新下载完整文件
+				os.Remove(part)
+				self.Request.SetResumeAt(0)
+				return "", 0, "", &TransferError{Err: errors.New("续传位置与已下载部分不一致")}
+			}
+			f, err = os.OpenFile(part, os.O_RDWR, 0666)
+			offset = start
+		}
+		if f == nil && err == nil {
+			f, err = os.OpenFile(part, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+		}
+	} else {
+		f, err = os.CreateTemp(config.FILE_TEMP_DIR, "*.tmp")
+	}
+	if err != nil {
+		return
+	}
+
+	h := opts.newHash()
+	if h != nil && offset > 0 {
+		// 已下载部分计入校验和
+		if _, err = io.Copy(h, io.LimitReader(f, offset)); err != nil {
+			f.Close()
+			return
+		}
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return
+	}
+	var w io.Writer = f
+	if h != nil {
+		w = io.MultiWriter(f, h)
+	}
+	body := &bodyReader{r: resp.Body}
+	var r io.Reader = body
+	if opts.MaxSize > 0 {
+		r = io.LimitReader(r, opts.MaxSize-offset+1)
+	}
+	n, err := io.Copy(w, r)
+	size = offset + n
+	if e := f.Close(); err == nil {
+		err = e
+	}
+
+	if err != nil {
+		if opts.Resume && size > 0 {
+			// 保留已下载部分，重试时续传，最终失败时由removePart删除
+			self.Request.SetResumeAt(size)
+		} else {
+			os.Remove(f.Name())
+			self.Request.SetResumeAt(0)
+		}
+		if body.err != nil {
+			err = &TransferError{Err: body.err}
+		}
+		return "", 0, "", err
+	}
+	if opts.MaxSize > 0 && size > opts.MaxSize {
+		os.Remove(f.Name())
+		self.Request.SetResumeAt(0)
+		return "", 0, "", ErrFileTooLarge
+	}
+
+	tmpName = f.Name()
+	if opts.Resume {
+		self.Request.SetResumeAt(0)
+		// 移至不与续传文件冲突的临时文件，等待输出
+		var t *os.File
+		if t, err = os.CreateTemp(config.FILE_TEMP_DIR, "*.tmp"); err != nil {
+			return "", 0, "", err
+		}
+		t.Close()
+		if err = os.Rename(part, t.Name()); err != nil {
+			os.Remove(t.Name())
+			return "", 0, "", err
+		}
+		tmpName = t.Name()
+	}
+	if h != nil {
+		checksum = strings.ToLower(opts.Checksum) + ":" + hex.EncodeToString(h.Sum(nil))
+	}
+	return tmpName, size, checksum, nil
+}
+
+// 续传时保存已下载部分的文件
+func partName(req *request.Request) string {
+	return filepath.Join(config.FILE_TEMP_DIR, req.Unique()+PART_EXT)
+}
+
+// 请求最终失败、不再重试时，删除其已下载部分
+func removePart(req *request.Request) {
+	if req.GetResumeAt() <= 0 {
+		return
+	}
+	req.SetResumeAt(0)
+	os.Remove(partName(req))
+}
+
+// 返回206响应Content-Range的起始字节
+func contentRangeStart(resp *http.Response) (int64, bool) {
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, false
+	}
+	var start, end, total int64
+	cr := resp.Header.Get("Content-Range")
+	if _, err := fmt.Sscanf(cr, "bytes %d-%d/%d", &start, &end, &total); err != nil {
+		if _, err = fmt.Sscanf(cr, "bytes %d-%d/*", &start, &end); err != nil {
+			return 0, false
+		}
+	}
+	return start, true
+}
@@ -0,0 +1,182 @@
+package spider
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/molast/crawler-core/app/downloader/request"
+	"github.com/molast/crawler-core/app/downloader/surfer"
+	"github.com/molast/crawler-core/app/scheduler"
+	"github.com/molast/crawler-core/config"
+	"github.com/molast/crawler-core/runtime/status"
+)
+
+// 读取n字节后返回错误，模拟下载中断
+type brokenReader struct {
+	r io.Reader
+	n int
+}
+
+func (self *brokenReader) Read(p []byte) (int, error) {
+	if self.n <= 0 {
+		return 0, errors.New("connection reset")
+	}
+	if len(p) > self.n {
+		p = p[:self.n]
+	}
+	n, err := self.r.Read(p)
+	self.n -= n
+	return n, err
+}
+
+func TestSaveBodyResume(t *testing.T) {
+	defer func(dir string) { config.FILE_TEMP_DIR = dir }(config.FILE_TEMP_DIR)
+	config.FILE_TEMP_DIR = t.TempDir()
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	req := &request.Request{Spider: "test", Url: "http://example.com/a.bin", Rule: "test"}
+	req.Prepare()
+	opts := &FileOptions{Checksum: "md5", Resume: true}
+
+	// 首次下载在第300字节中断
+	ctx := &Context{Request: req, Response: &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(&brokenReader{r: bytes.NewReader(content), n: 300}),
+	}}
+	if _, _, _, err := ctx.saveBody(opts); err == nil {
+		t.Fatal("expected error")
+	}
+	if at := req.GetResumeAt(); at != 300 || req.GetHeader().Get("Range") != "" {
+		t.Fatalf("resume at %d, Range = %q", at, req.GetHeader().Get("Range"))
+	}
+
+	// 续传剩余部分
+	ctx.Response = &http.Response{
+		StatusCode: http.StatusPartialContent,
+		Header:     http.Header{"Content-Range": {"bytes 300-999/1000"}},
+		Body:       ioutil.NopCloser(bytes.NewReader(content[300:])),
+	}
+	tmpName, size, checksum, err := ctx.saveBody(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(tmpName)
+	if size != 1000 || !bytes.Equal(b, content) {
+		t.Fatalf("size = %d, content mismatch", size)
+	}
+	sum := md5.Sum(content)
+	if checksum != "md5:"+hex.EncodeToString(sum[:]) {
+		t.Fatalf("checksum = %v", checksum)
+	}
+	if req.GetResumeAt() != 0 {
+		t.Fatal("resume offset not cleared")
+	}
+
+	// 最终失败时删除已下载部分
+	ctx.Response = &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(&brokenReader{r: bytes.NewReader(content), n: 300}),
+	}
+	if _, _, _, err := ctx.saveBody(opts); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := os.Stat(partName(req)); err != nil {
+		t.Fatal(err)
+	}
+	removePart(req)
+	if _, err := os.Stat(partName(req)); !os.IsNotExist(err) || req.GetResumeAt() != 0 {
+		t.Fatalf("part file not removed: %v", err)
+	}
+
+	// 超过大小限制
+	ctx.Response = &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{},
+		ContentLength: -1,
+		Body:          ioutil.NopCloser(bytes.NewReader(content)),
+	}
+	if _, _, _, err := ctx.saveBody(&FileOptions{MaxSize: 999}); err != ErrFileTooLarge {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestFileResumeRetry(t *testing.T) {
+	defer func(dir string) { config.FILE_TEMP_DIR = dir }(config.FILE_TEMP_DIR)
+	config.FILE_TEMP_DIR = t.TempDir()
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if r.Header.Get("Range") == "bytes=300-" && r.Header.Get("Accept-Encoding") == "identity" {
+			w.Header().Set("Content-Range", "bytes 300-999/1000")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(content[300:])
+			return
+		}
+		// 发送300字节后断开连接
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Write(content[:300])
+		w.(http.Flusher).Flush()
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer srv.Close()
+
+	sp := &Spider{Name: "resume", FileOptions: &FileOptions{Resume: true}}
+	sp.status = status.RUN
+	sp.reqMatrix = scheduler.AddMatrix(sp.Name, "", math.MinInt64)
+	req := &request.Request{Url: srv.URL + "/a.bin", Rule: "test", RetryPolicy: request.NewRetryPolicy()}
+	req.Prepare()
+
+	fetch := func() (ctx *Context, p interface{}) {
+		resp, err := surfer.New().Download(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx = &Context{spider: sp, Request: req, Response: resp}
+		defer func() { p = recover() }()
+		ctx.FileOutput()
+		return
+	}
+
+	// 下载中断时以TransferError崩溃，按下载错误分类后重试
+	_, p := fetch()
+	e, ok := p.(*TransferError)
+	if !ok {
+		t.Fatalf("panic = %v", p)
+	}
+	sp.DoFailure(req, request.Classify(e.Err, 0))
+	if req.FailTimes != 1 || req.GetResumeAt() != 300 {
+		t.Fatalf("FailTimes = %d, resume at %d", req.FailTimes, req.GetResumeAt())
+	}
+
+	// 重试时以Range请求续传
+	ctx, p := fetch()
+	if p != nil {
+		t.Fatalf("panic = %v", p)
+	}
+	files := ctx.PullFiles()
+	if len(files) != 1 {
+		t.Fatalf("files = %v", files)
+	}
+	b, _ := ioutil.ReadFile(files[0]["Path"].(string))
+	if !bytes.Equal(b, content) || len(ranges) != 2 || ranges[1] != "bytes=300-" {
+		t.Fatalf("ranges = %q, %d bytes", ranges, len(b))
+	}
+	if req.GetHeader().Get("Range") != "" {
+		t.Fatal("Range header persisted on the request")
+	}
+}
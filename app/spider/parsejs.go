@@ -22,7 +22,9 @@ type (
 		EnableLimit     bool        `xml:"EnableLimit"`
 		EnableKeyin     bool        `xml:"EnableKeyin"`
 		EnableCookie    bool        `xml:"EnableCookie"`
+		PersistCookie   bool        `xml:"PersistCookie"`
 		NotDefaultField bool        `xml:"NotDefaultField"`
+		OutDepth        bool        `xml:"OutDepth"`
 		Namespace       string      `xml:"Namespace>Script"`
 		SubNamespace    string      `xml:"SubNamespace>Script"`
 		Root            string      `xml:"Root>Script"`
@@ -43,7 +45,9 @@ func init() {
 			Description:     m.Description,
 			Pausetime:       m.Pausetime,
 			EnableCookie:    m.EnableCookie,
+			PersistCookie:   m.PersistCookie,
 			NotDefaultField: m.NotDefaultField,
+			OutDepth:        m.OutDepth,
 			RuleTree:        &RuleTree{Trunk: map[string]*Rule{}},
 		}
 		if m.EnableLimit {
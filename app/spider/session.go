@@ -0,0 +1,176 @@
+package spider
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/molast/crawler-core/app/downloader/request"
+	"github.com/molast/crawler-core/logs"
+)
+
+// Session 登录会话：声明登录过程及登录失效的判定方式，
+// 采集中检测到登录失效时暂停该Spider的请求，重新登录后将因此失败的请求重新加入队列
+type Session struct {
+	// 登录过程，client使用该Spider的cookie容器，登录成功后的cookie随之用于后续请求（须Spider.EnableCookie为true）
+	Login func(sp *Spider, client *http.Client) error
+	// 视为登录失效的响应状态码，如401、403
+	ExpiredStatus []int
+	// 最终（重定向后）的Url包含该字符串时视为登录失效，如"/login"
+	ExpiredUrl string
+	// 页面（仅文本内容，至多前BlockPeekSize字节）中存在匹配该CSS选择器的元素时视为登录失效，如"form#login"
+	ExpiredSelector string
+	// 自定义的登录失效判定，返回true时视为失效
+	Expired func(ctx *Context) bool
+	// 每次重新登录的最大尝试次数，均失败时停止自动登录，此后登录失效的请求按失败处理，0为3
+	MaxTries int
+	// 登录失败后再次尝试前的等待时长，0为5秒
+	RetryPause time.Duration
+	// 登录请求的超时时长，0为DefaultConnTimeout
+	Timeout time.Duration
+	// 同一请求因登录失效重新加入队列的最大次数（计入其失败次数），超过后按失败处理，0为3
+	MaxRequeues int
+}
+
+// 会话的运行状态，各Spider实例独立
+type sessionState struct {
+	generation int64 // 登录成功的次数，用于识别已被处理过的登录失效
+	disabled   bool  // 多次登录失败后停止自动登录
+	sync.Mutex
+}
+
+// 创建会话的运行状态
+func (self *Spider) sessionInit() {
+	self.session = nil
+	if self.Session == nil || self.Session.Login == nil {
+		return
+	}
+	self.session = new(sessionState)
+}
+
+// 首次登录，失败时在检测到登录失效后再次尝试
+func (self *Spider) sessionStart() {
+	if self.session == nil {
+		return
+	}
+	self.session.Lock()
+	defer self.session.Unlock()
+	if err := self.login(); err != nil {
+		logs.Log.Error(" *     Fail  [登录][%v]: %v\n", self.GetName(), err)
+		return
+	}
+	self.session.generation++
+}
+
+// SessionGeneration 返回当前登录的序号，下载前记录，用于Relogin判断登录失效是否已被处理
+func (self *Spider) SessionGeneration() int64 {
+	if self.session == nil {
+		return 0
+	}
+	self.session.Lock()
+	defer self.session.Unlock()
+	return self.session.generation
+}
+
+// SessionExpired 按Session的设置判断响应是否表明登录已失效
+func (self *Spider) SessionExpired(ctx *Context) bool {
+	if self.session == nil || ctx.Response == nil || ctx.Response.StatusCode == 0 {
+		return false
+	}
+	s := self.Session
+	for _, code := range s.ExpiredStatus {
+		if ctx.Response.StatusCode == code {
+			return true
+		}
+	}
+	if s.ExpiredUrl != "" && ctx.Response.Request != nil && ctx.Response.Request.URL != nil &&
+		strings.Contains(ctx.Response.Request.URL.String(), s.ExpiredUrl) {
+		return true
+	}
+	if ctx.err != nil {
+		return false
+	}
+	if s.ExpiredSelector != "" {
+		if dom := (&peekPage{ctx: ctx}).getDom(); dom != nil && dom.Find(s.ExpiredSelector).Length() > 0 {
+			return true
+		}
+	}
+	return s.Expired != nil && s.Expired(ctx)
+}
+
+// Relogin 将因登录失效而失败的请求重新加入队列，并在需要时暂停该Spider的请求、重新登录，
+// generation为下载前的SessionGeneration，返回false时表示已停止自动登录或该请求重新加入队列的次数已达上限，
+// 该请求须按失败处理
+func (self *Spider) Relogin(req *request.Request, generation int64) bool {
+	if self.session == nil {
+		return false
+	}
+	self.session.Lock()
+	defer self.session.Unlock()
+	if self.session.disabled {
+		return false
+	}
+	maxRequeues := self.Session.MaxRequeues
+	if maxRequeues <= 0 {
+		maxRequeues = 3
+	}
+	if req.FailTimes >= maxRequeues {
+		logs.Log.Warning(" *     [%v] 重新登录后仍登录失效%v次: %v\n", self.GetName(), req.FailTimes, req.GetUrl())
+		return false
+	}
+	req.FailTimes++
+	self.reqMatrix.Requeue(req)
+	// 下载后已重新登录过
+	if generation != self.session.generation {
+		return true
+	}
+
+	logs.Log.Informational(" *     [%v] 登录失效，重新登录\n", self.GetName())
+	self.reqMatrix.SetHold(true)
+	defer self.reqMatrix.SetHold(false)
+
+	maxTries, pause := self.Session.MaxTries, self.Session.RetryPause
+	if maxTries <= 0 {
+		maxTries = 3
+	}
+	if pause <= 0 {
+		pause = 5 * time.Second
+	}
+	for i := 1; ; i++ {
+		err := self.login()
+		if err == nil {
+			self.session.generation++
+			logs.Log.Informational(" *     [%v] 重新登录成功\n", self.GetName())
+			return true
+		}
+		logs.Log.Error(" *     Fail  [登录][%v] 第%v次: %v\n", self.GetName(), i, err)
+		if i >= maxTries || self.IsStopping() {
+			break
+		}
+		time.Sleep(pause)
+	}
+	self.session.disabled = true
+	logs.Log.Error(" *     [%v] 多次登录失败，停止自动登录\n", self.GetName())
+	return true
+}
+
+// 执行登录过程
+func (self *Spider) login() (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = errors.New("登录过程崩溃")
+			logs.Log.Error(" *     Panic  [login]: %v\n", p)
+		}
+	}()
+	timeout := self.Session.Timeout
+	if timeout <= 0 {
+		timeout = request.DefaultConnTimeout
+	}
+	client := &http.Client{Timeout: timeout}
+	if self.cookieJar != nil {
+		client.Jar = self.cookieJar
+	}
+	return self.Session.Login(self, client)
+}
@@ -0,0 +1,109 @@
+package spider
+
+import (
+	"errors"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/molast/crawler-core/app/aid/cookies"
+	"github.com/molast/crawler-core/app/downloader/request"
+	"github.com/molast/crawler-core/app/scheduler"
+	"github.com/molast/crawler-core/runtime/status"
+)
+
+func TestSession(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "ok", Path: "/"})
+	}))
+	defer srv.Close()
+
+	var logins int
+	var fail bool
+	sp := &Spider{
+		Name: "session",
+		Session: &Session{
+			Login: func(sp *Spider, client *http.Client) error {
+				logins++
+				if fail {
+					return errors.New("wrong password")
+				}
+				_, err := client.Get(srv.URL + "/login")
+				return err
+			},
+			ExpiredStatus:   []int{http.StatusUnauthorized},
+			ExpiredUrl:      "/login",
+			ExpiredSelector: "form#login",
+			MaxTries:        2,
+			RetryPause:      1,
+			MaxRequeues:     2,
+		},
+	}
+	sp.status = status.RUN
+	sp.reqMatrix = scheduler.AddMatrix(sp.Name, "", math.MinInt64)
+	sp.cookieJar = cookies.New()
+	sp.sessionInit()
+	sp.sessionStart()
+	if logins != 1 || sp.SessionGeneration() != 1 || len(sp.cookieJar.Get(srv.URL)) != 1 {
+		t.Fatalf("logins = %d, cookies = %v", logins, sp.cookieJar.Get(srv.URL))
+	}
+
+	// 判定登录失效
+	expired := func(code int, u string) bool {
+		URL, _ := url.Parse(u)
+		ctx := &Context{spider: sp, Response: &http.Response{StatusCode: code, Request: &http.Request{URL: URL}}}
+		return sp.SessionExpired(ctx)
+	}
+	if !expired(401, "http://a.com/x") || !expired(200, "http://a.com/login?next=x") || expired(200, "http://a.com/x") {
+		t.Fatal("SessionExpired")
+	}
+	// 按选择器判定后响应内容仍可完整读取
+	page := `<html><body><form id="login"></form></body></html>`
+	ctx := &Context{spider: sp, Response: &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": {"text/html"}},
+		Body:       ioutil.NopCloser(strings.NewReader(page)),
+	}}
+	if !sp.SessionExpired(ctx) {
+		t.Fatal("SessionExpired by selector")
+	}
+	if b, _ := ioutil.ReadAll(ctx.Response.Body); string(b) != page {
+		t.Fatalf("body = %q", b)
+	}
+
+	// 同一次登录失效只重新登录一次，请求均重新加入队列
+	generation := sp.SessionGeneration()
+	for i := 0; i < 3; i++ {
+		if !sp.Relogin(&request.Request{Url: "http://a.com/x"}, generation) {
+			t.Fatal("Relogin returned false")
+		}
+	}
+	if logins != 2 || sp.SessionGeneration() != 2 || sp.RequestLen() != 3 {
+		t.Fatalf("logins = %d, generation = %d, len = %d", logins, sp.SessionGeneration(), sp.RequestLen())
+	}
+
+	// 同一请求重新加入队列的次数有上限
+	req := &request.Request{Url: "http://a.com/w"}
+	for i := 0; i < 2; i++ {
+		if !sp.Relogin(req, generation) {
+			t.Fatal("Relogin returned false")
+		}
+	}
+	if sp.Relogin(req, generation) || req.FailTimes != 2 || sp.RequestLen() != 5 {
+		t.Fatalf("fails = %d, len = %d", req.FailTimes, sp.RequestLen())
+	}
+
+	// 多次登录失败后停止自动登录
+	fail = true
+	sp.Relogin(&request.Request{Url: "http://a.com/y"}, sp.SessionGeneration())
+	if logins != 4 || sp.Relogin(&request.Request{Url: "http://a.com/z"}, sp.SessionGeneration()) {
+		t.Fatalf("logins = %d", logins)
+	}
+	if sp.RequestLen() != 6 {
+		t.Fatalf("len = %d", sp.RequestLen())
+	}
+}
@@ -2,12 +2,18 @@ package spider
 
 import (
 	"math"
+	"net/http"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/molast/crawler-core/app/aid/cookies"
 	"github.com/molast/crawler-core/app/downloader/request"
+	"github.com/molast/crawler-core/app/downloader/warc"
 	"github.com/molast/crawler-core/app/scheduler"
 	"github.com/molast/crawler-core/common/util"
+	"github.com/molast/crawler-core/config"
 	"github.com/molast/crawler-core/logs"
 	"github.com/molast/crawler-core/runtime/status"
 )
@@ -29,17 +35,38 @@ type (
 		Keyin                     string                                                     // 自定义输入的配置信息，使用前须在规则中设置初始值为KEYIN
 		EnableCookie              bool                                                       // 所有请求是否使用cookie记录
 		NotDefaultField           bool                                                       // 是否禁止输出结果中的默认字段 Url/ParentUrl/DownloadTime
+		OutDepth                  bool                                                       // 是否在默认字段中追加输出请求深度 Depth，NotDefaultField为true时无效
 		Namespace                 func(self *Spider) string                                  // 命名空间，用于输出文件、路径的命名
 		SubNamespace              func(self *Spider, dataCell map[string]interface{}) string // 次级命名，用于输出文件、路径的命名，可依赖具体数据内容
 		RuleTree                  *RuleTree                                                  // 定义具体的采集规则树
 		ContinueSpiderWithFailure bool                                                       // 如果启动监测到历史记录中有爬取失败的记录时，true:任务和历史错误同时爬取，false：只爬取历史错误记录,此处使用golang bool默认值false
+		IgnoreRobots              bool                                                       // 是否忽略robots.txt协议（仅限已获得网站授权时使用）
+		RetryPolicy               *request.RetryPolicy                                       // 失败请求的重试策略，为nil时失败请求在队列末尾重试一次
+		Fingerprint               *request.Fingerprint                                       // 请求指纹（去重依据）的生成规则，为nil时按Spider+Rule+Url+Method计算
+		MaxDepth                  int                                                        // 请求与根请求的最大距离，超过时不再加入队列，0为不限
+		Weight                    int                                                        // 多个蜘蛛同时运行时分配并发资源的权重，0按1计算
+		MinShare                  int                                                        // 最少分配的并发资源量
+		MaxShare                  int                                                        // 最多分配的并发资源量，0为不限
+		RevisitInterval           time.Duration                                              // 增量抓取：成功请求在该间隔后可被重新抓取（发送条件请求，304时不再解析），0为不重新抓取
+		WarcArchive               bool                                                       // 是否将请求及响应存档为WARC文件（保存于WARC_DIR）
+		WarcReplay                string                                                     // WARC文件或目录，设置时以其中的响应代替实际下载，用于重新解析
+		FileOptions               *FileOptions                                               // FileOutput输出文件的选项（大小限制、校验和、断点续传），为nil时不做限制
+		PersistCookie             bool                                                       // 是否将cookie保存至COOKIE_DIR，下次运行时恢复
+		Session                   *Session                                                   // 登录会话，设置时开始采集前先登录，登录失效时自动重新登录
+		Block                     *Block                                                     // 封禁（含验证码页面）的检测及应对方式，被封禁的响应不再解析
 
 		// 以下字段系统自动赋值
-		id        int               // 自动分配的SpiderQueue中的索引
-		subName   string            // 由Keyin转换为的二级标识名
-		reqMatrix *scheduler.Matrix // 请求矩阵
-		timer     *Timer            // 定时器
-		status    int               // 执行状态
+		id        int                    // 自动分配的SpiderQueue中的索引
+		subName   string                 // 由Keyin转换为的二级标识名
+		reqMatrix *scheduler.Matrix      // 请求矩阵
+		warc      *warc.Writer           // WARC存档
+		replayer  *warc.Replayer         // WARC重放
+		cookieJar *cookies.Jar           // cookie容器，各Spider实例独立
+		session   *sessionState          // 登录会话的运行状态
+		block     *blockState            // 封禁应对的运行状态
+		push      func(*request.Request) // 接收新请求，默认为请求矩阵的Push，可由WithPush替换
+		timer     *Timer                 // 定时器
+		status    int                    // 执行状态
 		lock      sync.RWMutex
 		once      sync.Once
 	}
@@ -53,6 +80,7 @@ type (
 		ItemFields []string                                           // 结果字段列表(选填，写上可保证字段顺序)
 		ParseFunc  func(*Context)                                     // 内容解析函数
 		AidFunc    func(*Context, map[string]interface{}) interface{} // 通用辅助函数
+		MaxDepth   int                                                // 该规则请求的最大深度，覆盖Spider.MaxDepth，0为沿用Spider的设置
 	}
 )
 
@@ -203,7 +231,7 @@ func (self *Spider) RunTimer(id string) bool {
 }
 
 // Copy 返回一个自身复制品
-func (self *Spider) Copy() *Spider {
+func (self *Spider) Copy(opts ...CopyOption) *Spider {
 	ghost := &Spider{}
 	ghost.Name = self.Name
 	ghost.subName = self.subName
@@ -220,6 +248,7 @@ func (self *Spider) Copy() *Spider {
 
 		ghost.RuleTree.Trunk[k].ParseFunc = v.ParseFunc
 		ghost.RuleTree.Trunk[k].AidFunc = v.AidFunc
+		ghost.RuleTree.Trunk[k].MaxDepth = v.MaxDepth
 	}
 
 	ghost.Description = self.Description
@@ -229,16 +258,48 @@ func (self *Spider) Copy() *Spider {
 	ghost.Keyin = self.Keyin
 
 	ghost.NotDefaultField = self.NotDefaultField
+	ghost.OutDepth = self.OutDepth
 	ghost.Namespace = self.Namespace
 	ghost.SubNamespace = self.SubNamespace
 
 	ghost.timer = self.timer
 	ghost.status = self.status
 	ghost.ContinueSpiderWithFailure = self.ContinueSpiderWithFailure
-
+	ghost.IgnoreRobots = self.IgnoreRobots
+	ghost.RetryPolicy = self.RetryPolicy
+	ghost.Fingerprint = self.Fingerprint
+	ghost.MaxDepth = self.MaxDepth
+	ghost.Weight = self.Weight
+	ghost.MinShare = self.MinShare
+	ghost.MaxShare = self.MaxShare
+	ghost.RevisitInterval = self.RevisitInterval
+	ghost.WarcArchive = self.WarcArchive
+	ghost.WarcReplay = self.WarcReplay
+	ghost.FileOptions = self.FileOptions
+	ghost.PersistCookie = self.PersistCookie
+	ghost.Session = self.Session
+	ghost.Block = self.Block
+
+	for _, opt := range opts {
+		opt(ghost)
+	}
 	return ghost
 }
 
+// CopyOption 创建蜘蛛副本时的选项
+type CopyOption func(*Spider)
+
+// WithPush 副本不创建请求矩阵，Context.AddQueue添加的请求交由push处理，并将副本置为运行状态，
+// 用于在调度器之外运行蜘蛛，如spidertest
+func WithPush(push func(*request.Request)) CopyOption {
+	return func(self *Spider) {
+		self.push = push
+		self.cookieJar = cookies.New()
+		self.blockInit()
+		self.status = status.RUN
+	}
+}
+
 func (self *Spider) ReqmatrixInit() *Spider {
 	if self.Limit < 0 {
 		self.reqMatrix = scheduler.AddMatrix(self.GetName(), self.GetSubName(), self.Limit)
@@ -246,16 +307,103 @@ func (self *Spider) ReqmatrixInit() *Spider {
 	} else {
 		self.reqMatrix = scheduler.AddMatrix(self.GetName(), self.GetSubName(), math.MinInt64)
 	}
+	self.reqMatrix.SetIgnoreRobots(self.IgnoreRobots)
+	self.reqMatrix.SetShare(self.Weight, self.MinShare, self.MaxShare)
+	self.reqMatrix.SetRevisit(self.RevisitInterval)
+	self.reqMatrix.SetGiveUp(removePart)
+	self.push = self.reqMatrix.Push
+	ruleDepth := make(map[string]int)
+	for name, rule := range self.RuleTree.Trunk {
+		if rule.MaxDepth > 0 {
+			ruleDepth[name] = rule.MaxDepth
+		}
+	}
+	self.reqMatrix.SetMaxDepth(self.MaxDepth, ruleDepth)
+	self.warcInit()
+	self.cookieInit()
+	self.sessionInit()
+	self.blockInit()
 	return self
 }
 
+// 返回区分Spider实例的名称，用于存档、cookie等文件的命名
+func (self *Spider) instanceName() string {
+	name := self.GetName()
+	if self.GetSubName() != "" {
+		name += "__" + self.GetSubName()
+	}
+	return util.FileNameReplace(name)
+}
+
+// 按WarcArchive及WarcReplay初始化WARC存档及重放
+func (self *Spider) warcInit() {
+	self.warc, self.replayer = nil, nil
+	if self.WarcReplay != "" {
+		replayer, err := warc.NewReplayer(self.WarcReplay)
+		if err != nil {
+			logs.Log.Error(" *     Fail  [WARC重放][%v]: %v\n", self.WarcReplay, err)
+		} else {
+			self.replayer = replayer
+			logs.Log.Informational(" *     [%v] 从WARC存档重放 %v 个Url\n", self.GetName(), replayer.Len())
+		}
+		// 重放时不再重复存档
+		return
+	}
+	if self.WarcArchive {
+		self.warc = warc.NewWriter(config.WARC_DIR, self.instanceName(), config.WARC_MAX_SIZE)
+	}
+}
+
+// GetWarcWriter 返回WARC存档，未开启时返回nil
+func (self *Spider) GetWarcWriter() *warc.Writer {
+	return self.warc
+}
+
+// GetWarcReplayer 返回WARC重放，未设置WarcReplay时返回nil
+func (self *Spider) GetWarcReplayer() *warc.Replayer {
+	return self.replayer
+}
+
+// 创建cookie容器，PersistCookie时恢复上次保存的cookie
+func (self *Spider) cookieInit() {
+	self.cookieJar = cookies.New()
+	if !self.PersistCookie {
+		return
+	}
+	if err := self.cookieJar.Load(self.cookieFile()); err == nil {
+		logs.Log.Informational(" *     [%v] 恢复cookie %v 个\n", self.GetName(), len(self.cookieJar.Get("")))
+	} else if !os.IsNotExist(err) {
+		logs.Log.Error(" *     Fail  [恢复cookie][%v]: %v\n", self.GetName(), err)
+	}
+}
+
+// cookie的保存路径
+func (self *Spider) cookieFile() string {
+	return filepath.Join(config.COOKIE_DIR, self.instanceName()+".json")
+}
+
+// GetCookieJar 返回该Spider实例的cookie容器
+func (self *Spider) GetCookieJar() *cookies.Jar {
+	return self.cookieJar
+}
+
 // DoHistory 返回是否作为新的失败请求被添加至队列尾部
 func (self *Spider) DoHistory(req *request.Request, ok bool) bool {
 	return self.reqMatrix.DoHistory(req, ok)
 }
 
+// DoFailure 按重试策略处理失败请求，返回是否为该请求的首次失败
+func (self *Spider) DoFailure(req *request.Request, kind string) bool {
+	return self.reqMatrix.DoFailure(req, kind)
+}
+
+// Revisited 增量抓取模式下保存成功请求的校验信息
+func (self *Spider) Revisited(req *request.Request, resp *http.Response, hash string) {
+	self.reqMatrix.Revisited(req, resp, hash)
+}
+
 func (self *Spider) RequestPush(req *request.Request) {
-	self.reqMatrix.Push(req)
+	self.push(req)
 }
 
 func (self *Spider) RequestPull() *request.Request {
@@ -270,6 +418,20 @@ func (self *Spider) RequestFree() {
 	self.reqMatrix.Free()
 }
 
+func (self *Spider) RequestDone(req *request.Request) {
+	self.reqMatrix.Done(req)
+}
+
+// RequestFeedback 反馈请求的下载耗时及错误类型，用于自适应并发控制
+func (self *Spider) RequestFeedback(latency time.Duration, kind string) {
+	self.reqMatrix.Feedback(latency, kind)
+}
+
+// ProxyFeedback 反馈请求所用代理IP的使用结果，用于代理IP池的评分及停用
+func (self *Spider) ProxyFeedback(req *request.Request, latency time.Duration, kind string) {
+	scheduler.ProxyFeedback(req, latency, kind)
+}
+
 func (self *Spider) RequestLen() int {
 	return self.reqMatrix.Len()
 }
@@ -292,6 +454,12 @@ func (self *Spider) Start() {
 		self.status = status.RUN
 		self.lock.Unlock()
 	}()
+	// 需要登录时先登录
+	self.sessionStart()
+	// 存在上次中断时保存的请求队列时，从断点继续，不再执行Root
+	if self.reqMatrix.Resume() {
+		return
+	}
 	//如果有失败记录，则只处理爬取失败记录，不进行新的规则爬取
 	if self.reqMatrix.HasFaliure() == false || (self.reqMatrix.HasFaliure()) == true && self.ContinueSpiderWithFailure == true {
 		self.RuleTree.Root(GetContext(self, nil))
@@ -343,9 +511,28 @@ func (self *Spider) Defer() {
 	self.reqMatrix.Wait()
 	// 更新失败记录
 	self.reqMatrix.TryFlushFailure()
+	// 关闭持久化请求队列
+	self.reqMatrix.CloseFrontier()
+	// 关闭增量抓取的校验信息记录
+	self.reqMatrix.CloseRevisit()
+	// 关闭WARC存档
+	if self.warc != nil {
+		self.warc.Close()
+	}
+	// 保存cookie
+	if self.PersistCookie && self.cookieJar != nil {
+		if err := self.cookieJar.Save(self.cookieFile()); err != nil {
+			logs.Log.Error(" *     Fail  [保存cookie][%v]: %v\n", self.GetName(), err)
+		}
+	}
 }
 
 // OutDefaultField 是否输出默认添加的字段 Url/ParentUrl/DownloadTime
 func (self *Spider) OutDefaultField() bool {
 	return !self.NotDefaultField
 }
+
+// OutDepthField 是否在默认字段中追加输出请求深度 Depth
+func (self *Spider) OutDepthField() bool {
+	return !self.NotDefaultField && self.OutDepth
+}
@@ -0,0 +1,204 @@
+// Package spidertest 用于Spider采集规则的单元测试：
+// 以夹具目录或内存中的响应代替实际下载，运行蜘蛛并记录其添加的请求及输出的结果。
+package spidertest
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/molast/crawler-core/app/downloader/request"
+	"github.com/molast/crawler-core/app/spider"
+)
+
+// ErrNoFixture Url没有对应的夹具
+var ErrNoFixture = errors.New("没有对应的夹具")
+
+type (
+	// Downloader 从夹具返回响应的下载器，实现downloader.Downloader接口，并发安全
+	Downloader struct {
+		Dir       string               // 夹具目录，文件位置见FixturePath，为空时仅使用Responses
+		Responses map[string]*Response // [Url]响应，优先于Dir
+		requests  []*request.Request   // 已下载的请求
+		sync.Mutex
+	}
+	// Response 内存中的响应
+	Response struct {
+		StatusCode int         // 状态码，0为200
+		Header     http.Header // 响应头，未设置Content-Type时按Url扩展名或内容推断
+		Body       string      // 响应内容
+		Url        string      // 重定向后的最终Url，为空时同请求的Url
+		Err        error       // 模拟下载错误，如超时
+	}
+)
+
+// NewDownloader 创建从dir读取夹具的下载器，dir为空时仅使用Set添加的响应
+func NewDownloader(dir string) *Downloader {
+	return &Downloader{
+		Dir:       dir,
+		Responses: make(map[string]*Response),
+	}
+}
+
+// Set 设置Url对应的响应
+func (self *Downloader) Set(u string, resp *Response) *Downloader {
+	self.Lock()
+	defer self.Unlock()
+	if self.Responses == nil {
+		self.Responses = make(map[string]*Response)
+	}
+	self.Responses[u] = resp
+	return self
+}
+
+// SetBody 设置Url对应的200响应
+func (self *Downloader) SetBody(u, body string) *Downloader {
+	return self.Set(u, &Response{Body: body})
+}
+
+// Download 实现downloader.Downloader接口，没有对应的夹具时返回404及ErrNoFixture
+func (self *Downloader) Download(sp *spider.Spider, req *request.Request) *spider.Context {
+	ctx := spider.GetContext(sp, req)
+
+	self.Lock()
+	self.requests = append(self.requests, req)
+	resp, found := self.Responses[req.GetUrl()]
+	self.Unlock()
+
+	var err error
+	if !found {
+		resp, err = self.load(req.GetUrl())
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = ErrNoFixture
+		}
+		resp = &Response{StatusCode: http.StatusNotFound, Err: err}
+	}
+
+	httpResp := resp.build(req)
+	err = resp.Err
+	if err == nil && httpResp.StatusCode >= 400 {
+		err = errors.New("响应状态 " + httpResp.Status)
+	}
+	ctx.SetResponse(httpResp).SetError(err)
+	return ctx
+}
+
+// Requests 返回已下载的请求，按下载顺序
+func (self *Downloader) Requests() []*request.Request {
+	self.Lock()
+	defer self.Unlock()
+	return append([]*request.Request(nil), self.requests...)
+}
+
+// 从夹具目录读取响应，文件以"HTTP/"开头时按完整的HTTP响应解析（可指定状态码及响应头），否则视为响应内容
+func (self *Downloader) load(u string) (*Response, error) {
+	if self.Dir == "" {
+		return nil, ErrNoFixture
+	}
+	name, err := FixturePath(u)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(filepath.Join(self.Dir, name))
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(b, []byte("HTTP/")) {
+		return &Response{Body: string(b)}, nil
+	}
+	httpResp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(b)), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	body, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+	httpResp.Header.Del("Content-Length")
+	return &Response{
+		StatusCode: httpResp.StatusCode,
+		Header:     httpResp.Header,
+		Body:       string(body),
+	}, nil
+}
+
+// 生成http.Response，结构与实际下载的响应一致
+func (self *Response) build(req *request.Request) *http.Response {
+	code := self.StatusCode
+	if code == 0 {
+		code = http.StatusOK
+	}
+	header := make(http.Header)
+	for k, v := range self.Header {
+		header[k] = append([]string(nil), v...)
+	}
+	finalUrl := self.Url
+	if finalUrl == "" {
+		finalUrl = req.GetUrl()
+	}
+	URL, _ := url.Parse(finalUrl)
+	if header.Get("Content-Type") == "" && self.Body != "" {
+		contentType := ""
+		if URL != nil {
+			contentType = mime.TypeByExtension(path.Ext(URL.Path))
+		}
+		if contentType == "" {
+			contentType = http.DetectContentType([]byte(self.Body))
+		}
+		header.Set("Content-Type", contentType)
+	}
+	var host string
+	if URL != nil {
+		host = URL.Host
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(code) + " " + http.StatusText(code),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(strings.NewReader(self.Body)),
+		ContentLength: int64(len(self.Body)),
+		Request: &http.Request{
+			Method: req.GetMethod(),
+			URL:    URL,
+			Header: req.GetHeader(),
+			Host:   host,
+		},
+	}
+}
+
+// FixturePath 返回Url对应的夹具文件在夹具目录中的相对路径：主机/路径，
+// 路径为空或以"/"结尾时补"index.html"，带查询参数时文件名后追加"@"及转义后的查询参数，
+// 如http://a.com/list?page=2对应a.com/list@page%3D2
+func FixturePath(u string) (string, error) {
+	URL, err := url.Parse(u)
+	if err != nil {
+		return "", err
+	}
+	if URL.Host == "" {
+		return "", errors.New("Url缺少主机: " + u)
+	}
+	p := URL.Path
+	if p == "" || strings.HasSuffix(p, "/") {
+		p += "index.html"
+	}
+	if URL.RawQuery != "" {
+		p += "@" + url.QueryEscape(URL.RawQuery)
+	}
+	return filepath.FromSlash(strings.Replace(URL.Host, ":", "_", -1) + path.Clean("/"+p)), nil
+}
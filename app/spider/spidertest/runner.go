@@ -0,0 +1,169 @@
+package spidertest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/molast/crawler-core/app/downloader"
+	"github.com/molast/crawler-core/app/downloader/request"
+	"github.com/molast/crawler-core/app/pipeline/collector/data"
+	"github.com/molast/crawler-core/app/spider"
+)
+
+// 默认最多处理的请求数
+const DefaultMaxRequests = 1000
+
+type (
+	// Runner 使用指定的下载器在当前协程中运行蜘蛛，不经过调度器及输出管道，
+	// 请求按优先级从高到低、同优先级按添加顺序逐个处理，因此结果是确定的
+	Runner struct {
+		Downloader  downloader.Downloader // 下载器，通常为*Downloader
+		MaxRequests int                   // 最多处理的请求数，0为DefaultMaxRequests
+		Keyin       string                // 自定义输入，蜘蛛使用KEYIN时设置
+	}
+	// Result 运行结果
+	Result struct {
+		Requests   []*request.Request // AddQueue添加的所有请求，按添加顺序，含被去重的请求
+		Downloaded []*request.Request // 实际处理的请求，按处理顺序
+		Items      []data.DataCell    // Output输出的文本结果，按输出顺序
+		Files      []data.FileCell    // FileOutput输出的文件结果，文件内容位于Path指向的临时文件
+		Failures   []Failure          // 下载失败、被封禁或解析崩溃的请求
+	}
+	// Failure 失败的请求
+	Failure struct {
+		Request *request.Request // 为nil时表示Root崩溃
+		Err     error
+	}
+)
+
+// New 创建使用下载器d的Runner
+func New(d downloader.Downloader) *Runner {
+	return &Runner{Downloader: d}
+}
+
+// Run 运行蜘蛛副本：先执行Root，再依次下载并解析队列中的请求，直至队列为空或达到MaxRequests
+func (self *Runner) Run(sp *spider.Spider) *Result {
+	var (
+		result = new(Result)
+		queue  []*request.Request
+		seen   = make(map[string]bool)
+	)
+	push := func(req *request.Request) {
+		result.Requests = append(result.Requests, req)
+		if !req.IsReloadable() {
+			if seen[req.Unique()] {
+				return
+			}
+			seen[req.Unique()] = true
+		}
+		queue = append(queue, req)
+	}
+	sp = sp.Copy(spider.WithPush(push))
+	if self.Keyin != "" {
+		sp.SetKeyin(self.Keyin)
+	}
+
+	ctx := spider.GetContext(sp, nil)
+	if err := self.call(func() { sp.RuleTree.Root(ctx) }); err != nil {
+		result.Failures = append(result.Failures, Failure{Err: err})
+	}
+	self.collect(result, ctx)
+
+	maxRequests := self.MaxRequests
+	if maxRequests <= 0 {
+		maxRequests = DefaultMaxRequests
+	}
+	for len(queue) > 0 && len(result.Downloaded) < maxRequests {
+		// 取出优先级最高的请求中最早添加者
+		idx := 0
+		for i, req := range queue {
+			if req.GetPriority() > queue[idx].GetPriority() {
+				idx = i
+			}
+		}
+		req := queue[idx]
+		queue = append(queue[:idx], queue[idx+1:]...)
+		result.Downloaded = append(result.Downloaded, req)
+
+		ctx := self.Downloader.Download(sp, req)
+		// 被封禁的响应不再解析
+		if sp.DetectBlock(ctx) != nil {
+			result.Failures = append(result.Failures, Failure{Request: req, Err: spider.ErrBlocked})
+			spider.PutContext(ctx)
+			continue
+		}
+		if err := ctx.GetError(); err != nil {
+			result.Failures = append(result.Failures, Failure{Request: req, Err: err})
+			spider.PutContext(ctx)
+			continue
+		}
+		if err := self.call(func() { ctx.Parse(req.GetRuleName()) }); err != nil {
+			result.Failures = append(result.Failures, Failure{Request: req, Err: err})
+		}
+		self.collect(result, ctx)
+	}
+	return result
+}
+
+// 执行规则函数，崩溃时返回错误
+func (self *Runner) call(fn func()) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%v", p)
+		}
+	}()
+	fn()
+	return
+}
+
+// 收集Context中的结果并释放Context
+func (self *Runner) collect(result *Result, ctx *spider.Context) {
+	result.Items = append(result.Items, ctx.PullItems()...)
+	result.Files = append(result.Files, ctx.PullFiles()...)
+	spider.PutContext(ctx)
+}
+
+// ItemsOf 返回规则ruleName输出的文本结果内容
+func (self *Result) ItemsOf(ruleName string) []map[string]interface{} {
+	var items []map[string]interface{}
+	for _, item := range self.Items {
+		if item["RuleName"] == ruleName {
+			data, _ := item["Data"].(map[string]interface{})
+			items = append(items, data)
+		}
+	}
+	return items
+}
+
+// RequestsOf 返回添加的由规则ruleName解析的请求
+func (self *Result) RequestsOf(ruleName string) []*request.Request {
+	var reqs []*request.Request
+	for _, req := range self.Requests {
+		if req.GetRuleName() == ruleName {
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs
+}
+
+// Err 返回首个失败的错误，没有失败时返回nil
+func (self *Result) Err() error {
+	if len(self.Failures) == 0 {
+		return nil
+	}
+	f := self.Failures[0]
+	if f.Request == nil {
+		return errors.New("Root: " + f.Err.Error())
+	}
+	return fmt.Errorf("%v: %v", f.Request.GetUrl(), f.Err)
+}
+
+// Cleanup 删除文件结果的临时文件
+func (self *Result) Cleanup() {
+	for _, f := range self.Files {
+		if p, ok := f["Path"].(string); ok {
+			os.Remove(p)
+		}
+	}
+}
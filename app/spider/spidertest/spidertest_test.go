@@ -0,0 +1,125 @@
+package spidertest
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/molast/crawler-core/app/downloader/request"
+	"github.com/molast/crawler-core/app/spider"
+)
+
+var shop = &spider.Spider{
+	Name: "shop",
+	RuleTree: &spider.RuleTree{
+		Root: func(ctx *spider.Context) {
+			ctx.AddQueue(&request.Request{Url: "http://shop.example.com/", Rule: "list"})
+		},
+		Trunk: map[string]*spider.Rule{
+			"list": {
+				ParseFunc: func(ctx *spider.Context) {
+					ctx.GetDom().Find("a.item").Each(func(i int, s *goquery.Selection) {
+						href, _ := s.Attr("href")
+						ctx.AddQueue(&request.Request{Url: "http://shop.example.com" + href, Rule: "item"})
+					})
+				},
+			},
+			"item": {
+				ItemFields: []string{"name", "price"},
+				ParseFunc: func(ctx *spider.Context) {
+					dom := ctx.GetDom()
+					ctx.Output(map[int]interface{}{
+						0: dom.Find("h1").Text(),
+						1: dom.Find(".price").Text(),
+					})
+					if src, ok := dom.Find("img").Attr("src"); ok {
+						ctx.AddQueue(&request.Request{Url: "http://shop.example.com" + src, Rule: "image"})
+					}
+				},
+			},
+			"image": {
+				ParseFunc: func(ctx *spider.Context) {
+					ctx.FileOutput()
+				},
+			},
+		},
+	},
+}
+
+func TestRunner(t *testing.T) {
+	d := NewDownloader("testdata").
+		Set("http://shop.example.com/img/1.png", &Response{Body: "\x89PNG"})
+	result := New(d).Run(shop)
+	defer result.Cleanup()
+
+	// 重复的请求被记录但不重复处理，没有夹具的请求计为失败
+	if len(result.Requests) != 6 || len(result.RequestsOf("item")) != 4 || len(result.Downloaded) != 5 {
+		t.Fatalf("requests = %d, downloaded = %d", len(result.Requests), len(result.Downloaded))
+	}
+	if len(result.Failures) != 1 || result.Failures[0].Request.GetUrl() != "http://shop.example.com/item/3" ||
+		result.Failures[0].Err.Error() != "没有对应的夹具" {
+		t.Fatalf("failures = %v", result.Err())
+	}
+	if req := result.RequestsOf("image")[0]; req.GetDepth() != 2 || req.GetReferer() != "http://shop.example.com/item/1" {
+		t.Fatalf("image request: depth %d, referer %q", req.GetDepth(), req.GetReferer())
+	}
+
+	items := result.ItemsOf("item")
+	if len(items) != 2 || items[0]["name"] != "一号商品" || items[1]["price"] != "20" {
+		t.Fatalf("items = %v", items)
+	}
+
+	if len(result.Files) != 1 || result.Files[0]["Name"] != "1.png" {
+		t.Fatalf("files = %v", result.Files)
+	}
+	b, err := ioutil.ReadFile(result.Files[0]["Path"].(string))
+	if err != nil || string(b) != "\x89PNG" {
+		t.Fatalf("file content %q, %v", b, err)
+	}
+}
+
+func TestFixturePath(t *testing.T) {
+	cases := map[string]string{
+		"http://a.com":             "a.com/index.html",
+		"http://a.com/list/":       "a.com/list/index.html",
+		"https://a.com:8443/x.htm": "a.com_8443/x.htm",
+		"http://a.com/list?page=2": "a.com/list@page%3D2",
+	}
+	for u, want := range cases {
+		if got, err := FixturePath(u); err != nil || got != want {
+			t.Fatalf("%v: got %v, %v, want %v", u, got, err, want)
+		}
+	}
+}
+
+func TestRunnerBlock(t *testing.T) {
+	sp := &spider.Spider{
+		Name:  "block",
+		Block: &spider.Block{Detectors: []*spider.BlockDetector{{Name: "captcha", Selector: "#captcha"}}},
+		RuleTree: &spider.RuleTree{
+			Root: func(ctx *spider.Context) {
+				ctx.AddQueue(&request.Request{Url: "http://a.com/1", Rule: "page"})
+				ctx.AddQueue(&request.Request{Url: "http://a.com/2", Rule: "page"})
+			},
+			Trunk: map[string]*spider.Rule{
+				"page": {
+					ParseFunc: func(ctx *spider.Context) {
+						ctx.Output(map[string]interface{}{"title": ctx.GetDom().Find("title").Text()})
+					},
+				},
+			},
+		},
+	}
+	d := NewDownloader("").
+		SetBody("http://a.com/1", "<title>ok</title>").
+		SetBody("http://a.com/2", `<title>verify</title><div id="captcha"></div>`)
+	result := New(d).Run(sp)
+
+	// 被封禁的响应计为失败，不再解析
+	if len(result.Failures) != 1 || result.Failures[0].Err != spider.ErrBlocked || result.Failures[0].Request.GetUrl() != "http://a.com/2" {
+		t.Fatalf("failures = %v", result.Err())
+	}
+	if items := result.ItemsOf("page"); len(items) != 1 || items[0]["title"] != "ok" {
+		t.Fatalf("items = %v", items)
+	}
+}
@@ -0,0 +1,248 @@
+// Package bloom implements fixed-size and scalable bloom filters based on bitset.
+package bloom
+
+import (
+	"encoding/gob"
+	"hash/fnv"
+	"io"
+	"math"
+	"sync"
+
+	"github.com/molast/crawler-core/common/goutil/bitset"
+)
+
+const (
+	// tightening ratio of the false positive rate between sub filters.
+	tightening = 0.5
+	// growth factor of the capacity between sub filters.
+	growth = 2
+)
+
+// Filter is a fixed-size bloom filter.
+type Filter struct {
+	bits     *bitset.BitSet
+	m        uint64 // bits size
+	k        uint64 // hash functions count
+	capacity uint64 // expected max items count
+	count    uint64 // added items count
+}
+
+// NewFilter creates a bloom filter that holds capacity items
+// with the specified false positive rate.
+func NewFilter(capacity uint64, fpRate float64) *Filter {
+	if capacity == 0 {
+		capacity = 1
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.001
+	}
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	m = (m + 7) / 8 * 8
+	k := uint64(math.Round(float64(m) / float64(capacity) * math.Ln2))
+	if k == 0 {
+		k = 1
+	}
+	return &Filter{
+		bits:     bitset.New(make([]byte, m/8)...),
+		m:        m,
+		k:        k,
+		capacity: capacity,
+	}
+}
+
+// Add adds the key, and returns false if the key probably existed.
+func (f *Filter) Add(key []byte) bool {
+	h1, h2 := hashes(key)
+	isNew := false
+	for i := uint64(0); i < f.k; i++ {
+		old, _ := f.bits.Set(int((h1+i*h2)%f.m), true)
+		if !old {
+			isNew = true
+		}
+	}
+	if isNew {
+		f.count++
+	}
+	return isNew
+}
+
+// Has returns whether the key probably exists.
+func (f *Filter) Has(key []byte) bool {
+	h1, h2 := hashes(key)
+	for i := uint64(0); i < f.k; i++ {
+		if !f.bits.Get(int((h1 + i*h2) % f.m)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Count returns the added items count.
+func (f *Filter) Count() uint64 {
+	return f.count
+}
+
+// full returns whether the filter reaches its capacity.
+func (f *Filter) full() bool {
+	return f.count >= f.capacity
+}
+
+func hashes(key []byte) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write(key)
+	h1 := h.Sum64()
+	h = fnv.New64()
+	h.Write(key)
+	h2 := h.Sum64() | 1
+	return h1, h2
+}
+
+// Scalable is a concurrent safe bloom filter that grows automatically,
+// keeping the total false positive rate under the specified value.
+type Scalable struct {
+	filters  []*Filter
+	capacity uint64
+	fpRate   float64
+	mu       sync.RWMutex
+}
+
+// NewScalable creates a scalable bloom filter,
+// capacity is the initial capacity, fpRate is the total false positive rate.
+func NewScalable(capacity uint64, fpRate float64) *Scalable {
+	if capacity == 0 {
+		capacity = 1 << 16
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.001
+	}
+	s := &Scalable{
+		capacity: capacity,
+		fpRate:   fpRate,
+	}
+	s.grow()
+	return s
+}
+
+func (s *Scalable) grow() {
+	n := len(s.filters)
+	capacity := s.capacity * uint64(math.Pow(growth, float64(n)))
+	fpRate := s.fpRate * (1 - tightening) * math.Pow(tightening, float64(n))
+	s.filters = append(s.filters, NewFilter(capacity, fpRate))
+}
+
+// Add adds the key, and returns false if the key probably existed.
+func (s *Scalable) Add(key []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range s.filters {
+		if f.Has(key) {
+			return false
+		}
+	}
+	last := s.filters[len(s.filters)-1]
+	if last.full() {
+		s.grow()
+		last = s.filters[len(s.filters)-1]
+	}
+	return last.Add(key)
+}
+
+// Has returns whether the key probably exists.
+func (s *Scalable) Has(key []byte) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, f := range s.filters {
+		if f.Has(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the added items count.
+func (s *Scalable) Count() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var n uint64
+	for _, f := range s.filters {
+		n += f.count
+	}
+	return n
+}
+
+// FPRate returns the total false positive rate.
+func (s *Scalable) FPRate() float64 {
+	return s.fpRate
+}
+
+type (
+	filterData struct {
+		M, K, Capacity, Count uint64
+		Bits                  []byte
+	}
+	scalableData struct {
+		Capacity uint64
+		FPRate   float64
+		Filters  []filterData
+	}
+)
+
+// WriteTo writes the filter to w.
+func (s *Scalable) WriteTo(w io.Writer) (int64, error) {
+	s.mu.RLock()
+	data := scalableData{
+		Capacity: s.capacity,
+		FPRate:   s.fpRate,
+		Filters:  make([]filterData, len(s.filters)),
+	}
+	for i, f := range s.filters {
+		data.Filters[i] = filterData{
+			M:        f.m,
+			K:        f.k,
+			Capacity: f.capacity,
+			Count:    f.count,
+			Bits:     f.bits.Bytes(),
+		}
+	}
+	s.mu.RUnlock()
+	cw := &countWriter{w: w}
+	err := gob.NewEncoder(cw).Encode(&data)
+	return cw.n, err
+}
+
+// ReadScalable reads a scalable bloom filter written by WriteTo from r.
+func ReadScalable(r io.Reader) (*Scalable, error) {
+	var data scalableData
+	if err := gob.NewDecoder(r).Decode(&data); err != nil {
+		return nil, err
+	}
+	s := &Scalable{
+		capacity: data.Capacity,
+		fpRate:   data.FPRate,
+		filters:  make([]*Filter, len(data.Filters)),
+	}
+	for i, f := range data.Filters {
+		s.filters[i] = &Filter{
+			bits:     bitset.New(f.Bits...),
+			m:        f.M,
+			k:        f.K,
+			capacity: f.Capacity,
+			count:    f.Count,
+		}
+	}
+	if len(s.filters) == 0 {
+		s.grow()
+	}
+	return s, nil
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
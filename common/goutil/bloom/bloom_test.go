@@ -0,0 +1,86 @@
+package bloom_test
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/molast/crawler-core/common/goutil/bloom"
+)
+
+func TestScalable(t *testing.T) {
+	const n = 20000
+	s := bloom.NewScalable(1000, 0.01)
+	for i := 0; i < n; i++ {
+		s.Add([]byte(strconv.Itoa(i)))
+	}
+	for i := 0; i < n; i++ {
+		if !s.Has([]byte(strconv.Itoa(i))) {
+			t.Fatalf("false negative: %d", i)
+		}
+	}
+	var fp int
+	for i := n; i < 2*n; i++ {
+		if s.Has([]byte(strconv.Itoa(i))) {
+			fp++
+		}
+	}
+	if rate := float64(fp) / n; rate > 0.02 {
+		t.Fatalf("false positive rate: get %v, want <= %v", rate, 0.01)
+	}
+	if s.Add([]byte("0")) {
+		t.Fatal("existed key added again")
+	}
+
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	s2, err := bloom.ReadScalable(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s2.Count() != s.Count() {
+		t.Fatalf("count: get %d, want %d", s2.Count(), s.Count())
+	}
+	for i := 0; i < n; i++ {
+		if !s2.Has([]byte(strconv.Itoa(i))) {
+			t.Fatalf("false negative after reload: %d", i)
+		}
+	}
+}
+
+func TestCounting(t *testing.T) {
+	const n = 5000
+	c := bloom.NewCounting(n, 0.01)
+	for i := 0; i < n; i++ {
+		c.Add([]byte(strconv.Itoa(i)))
+	}
+	for i := 0; i < n; i += 2 {
+		if !c.Remove([]byte(strconv.Itoa(i))) {
+			t.Fatalf("remove: %d", i)
+		}
+	}
+	if c.Count() != n/2 {
+		t.Fatalf("count: %d", c.Count())
+	}
+	var fp int
+	for i := 0; i < n; i++ {
+		has := c.Has([]byte(strconv.Itoa(i)))
+		if i%2 == 1 && !has {
+			t.Fatalf("false negative: %d", i)
+		}
+		if i%2 == 0 && has {
+			fp++
+		}
+	}
+	if rate := float64(fp) / (n / 2); rate > 0.02 {
+		t.Fatalf("false positive rate: %v", rate)
+	}
+	for i := 1; i < n; i += 2 {
+		c.Remove([]byte(strconv.Itoa(i)))
+	}
+	if c.Count() != 0 || c.Has([]byte("1")) {
+		t.Fatal("filter should be empty")
+	}
+}
@@ -0,0 +1,71 @@
+package bloom
+
+import "math"
+
+// Counting is a fixed-size counting bloom filter that supports removal. Not concurrent safe.
+// The false positive rate rises when it holds more items than its capacity.
+// Counters saturate at 255 and are never decremented afterwards.
+type Counting struct {
+	counters []uint8
+	m        uint64 // counters size
+	k        uint64 // hash functions count
+	count    uint64 // items count
+}
+
+// NewCounting creates a counting bloom filter that holds capacity items
+// with the specified false positive rate.
+func NewCounting(capacity uint64, fpRate float64) *Counting {
+	f := NewFilter(capacity, fpRate)
+	return &Counting{
+		counters: make([]uint8, f.m),
+		m:        f.m,
+		k:        f.k,
+	}
+}
+
+// Add adds the key once, the same key can be added several times.
+func (c *Counting) Add(key []byte) {
+	h1, h2 := hashes(key)
+	for i := uint64(0); i < c.k; i++ {
+		if j := (h1 + i*h2) % c.m; c.counters[j] < math.MaxUint8 {
+			c.counters[j]++
+		}
+	}
+	c.count++
+}
+
+// Remove removes the key once, and returns false if the key did not exist.
+// Only keys that have been added should be removed,
+// removing a false positive key leads to false negatives of other keys.
+func (c *Counting) Remove(key []byte) bool {
+	h1, h2 := hashes(key)
+	if !c.has(h1, h2) {
+		return false
+	}
+	for i := uint64(0); i < c.k; i++ {
+		if j := (h1 + i*h2) % c.m; c.counters[j] < math.MaxUint8 {
+			c.counters[j]--
+		}
+	}
+	c.count--
+	return true
+}
+
+// Has returns whether the key probably exists.
+func (c *Counting) Has(key []byte) bool {
+	return c.has(hashes(key))
+}
+
+// Count returns the items count.
+func (c *Counting) Count() uint64 {
+	return c.count
+}
+
+func (c *Counting) has(h1, h2 uint64) bool {
+	for i := uint64(0); i < c.k; i++ {
+		if c.counters[(h1+i*h2)%c.m] == 0 {
+			return false
+		}
+	}
+	return true
+}
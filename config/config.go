@@ -21,6 +21,12 @@ const (
 	PHANTOMJS_TEMP        = CACHE_DIR                       // Surfer-Phantom下载器：js文件临时目录
 	HISTORY_TAG    string = "history"                       // 历史记录的标识符
 	HISTORY_DIR           = WORK_ROOT + "/" + HISTORY_TAG   // excel或csv输出方式下，历史记录目录
+	FRONTIER_TAG   string = "frontier"                      // 持久化请求队列的标识符
+	FRONTIER_DIR          = WORK_ROOT + "/" + FRONTIER_TAG  // 持久化请求队列目录（断点续爬）
+	RESPCACHE_TAG  string = "respcache"                     // 响应缓存的标识符
+	RESPCACHE_DIR         = WORK_ROOT + "/" + RESPCACHE_TAG // 响应缓存目录
+	COOKIE_TAG     string = "cookie"                        // cookie持久化的标识符
+	COOKIE_DIR            = WORK_ROOT + "/" + COOKIE_TAG    // 持久化的cookie目录
 	SPIDER_EXT     string = ".crawler.html"                 // 动态规则扩展名
 )
 
@@ -31,7 +37,10 @@ var (
 	PROXY                    = setting.GetString("proxylib")   // 代理IP文件路径
 	SPIDER_DIR               = setting.GetString("spiderdir")  // 动态规则目录
 	FILE_DIR                 = setting.GetString("fileoutdir") // 文件（图片、HTML等）结果的输出目录
+	FILE_TEMP_DIR            = FILE_DIR + "/.tmp"              // 文件结果下载过程中的临时目录
 	TEXT_DIR                 = setting.GetString("textoutdir") // excel或csv输出方式下，文本结果的输出目录
+	WARC_DIR                 = setting.GetString("warcoutdir") // WARC存档文件的输出目录
+	WARC_MAX_SIZE            = setting.GetInt64("warcmaxsize") // 单个WARC文件的最大字节数，超过时新建文件
 	DB_NAME                  = setting.GetString("dbname")     // 数据库名称
 	MGO_ADMIN_USERNAME       = setting.GetString("mgo.username")
 	MGO_ADMIN_PASSWORD       = setting.GetString("mgo.password")
@@ -42,6 +51,24 @@ var (
 	MYSQL_CONN_CAP           = setting.GetInt("mysql.conncap")                  // mysql连接池容量
 	MYSQL_MAX_ALLOWED_PACKET = setting.GetInt("mysql.maxallowedpacket")         // mysql通信缓冲区的最大长度
 	KAFKA_BORKERS            = setting.GetString("kafka.brokers")               // kafka brokers
+	PROXY_STRATEGY           = setting.GetString("proxy.strategy")              // 代理IP的选择策略：round-robin、least-used、weighted
+	PROXY_STICKY             = setting.GetBool("proxy.sticky")                  // 同一主机的请求是否固定使用同一代理IP
+	PROXY_PROBE_URL          = setting.GetString("proxy.probeurl")              // 代理IP健康检查的探测地址，为空时不检查
+	PROXY_PROBE_SECOND       = setting.GetInt64("proxy.probesecond")            // 代理IP健康检查的间隔秒数
+	PROXY_BAN_AFTER          = setting.GetInt("proxy.banafter")                 // 代理IP连续失败该次数后暂时停用
+	PROXY_BAN_SECOND         = setting.GetInt64("proxy.bansecond")              // 代理IP暂时停用的秒数
+	PROXY_PROVIDER           = setting.GetString("proxy.provider")              // 代理IP的来源：file、static、api
+	PROXY_LIST               = setting.GetString("proxy.list")                  // static来源的代理IP列表,逗号分割
+	PROXY_API                = setting.GetString("proxy.api")                   // api来源的代理IP获取地址，返回文本或JSON
+	DNS_SERVERS              = setting.GetString("dns.servers")                 // 上游DNS服务器,逗号分割，为空时使用系统解析器
+	DNS_TTL                  = setting.GetInt64("dns.ttl")                      // DNS缓存时长的上限（秒）
+	DNS_NEGATIVE_TTL         = setting.GetInt64("dns.negativettl")              // 域名不存在时的缓存时长（秒）
+	HTTP_MAX_IDLE_CONNS      = setting.GetInt("http.maxidleconns")              // 下载连接池所有主机的最大空闲连接数，0为不限
+	HTTP_MAX_IDLE_PER_HOST   = setting.GetInt("http.maxidleperhost")            // 下载连接池每个主机的最大空闲连接数
+	HTTP_MAX_CONNS_PER_HOST  = setting.GetInt("http.maxconnsperhost")           // 下载连接池每个主机的最大连接数，0为不限
+	HTTP_IDLE_SECOND         = setting.GetInt64("http.idlesecond")              // 下载连接池空闲连接的保持秒数，0为不限
+	HTTP_DISABLE_KEEPALIVES  = setting.GetBool("http.disablekeepalives")        // 是否禁用长连接
+	HTTP_DISABLE_HTTP2       = setting.GetBool("http.disablehttp2")             // 是否禁用HTTP/2
 	LOG_CAP                  = setting.GetInt64("log.cap")                      // 日志缓存的容量
 	LOG_LEVEL                = logLevel(setting.GetString("log.level"))         // 全局日志打印级别（亦是日志文件输出级别）
 	LOG_CONSOLE_LEVEL        = logLevel(setting.GetString("log.consolelevel"))  // 日志在控制台的显示级别
@@ -62,8 +89,22 @@ func init() {
 		DockerCap:       setting.GetInt("run.dockercap"),        // 分段转储容器容量
 		Limit:           setting.GetInt64("run.limit"),          // 采集上限，0为不限，若在规则中设置初始值为LIMIT则为自定义限制，否则默认限制请求数
 		ProxySecond:     setting.GetInt64("run.proxysecond"),    // 代理IP更换的间隔秒钟数
+		HostRate:        setting.GetFloat64("run.hostrate"),     // 每个主机每秒最大请求数，0为不限
+		HostBurst:       setting.GetInt("run.hostburst"),        // 每个主机允许的突发请求数
+		HostConns:       setting.GetInt("run.hostconns"),        // 每个主机最大并发连接数，0为不限
+		HostByIP:        setting.GetBool("run.hostbyip"),        // 按解析后的IP而非主机名限速
+		BloomAfter:      setting.GetInt("run.bloomafter"),       // 成功记录超过该数量时改用布隆过滤器去重，0为始终精确去重
+		BloomRate:       setting.GetFloat64("run.bloomrate"),    // 布隆过滤器的误判率
+		AutoThrottle:    setting.GetBool("run.autothrottle"),    // 根据响应延迟及错误率自动调整并发量与请求间隔
+		AutoMinThread:   setting.GetInt("run.autominthread"),    // 自适应并发的最小并发量
+		AutoMaxThread:   setting.GetInt("run.automaxthread"),    // 自适应并发的最大并发量，0为全局并发量
+		AutoMinDelay:    setting.GetInt64("run.automindelay"),   // 自适应并发的最小请求间隔（毫秒）
+		AutoMaxDelay:    setting.GetInt64("run.automaxdelay"),   // 自适应并发的最大请求间隔（毫秒），0为不限
+		CacheMode:       setting.GetString("run.cachemode"),     // 响应缓存模式：off、record、replay-only、prefer-cache
+		CacheExpire:     setting.GetInt64("run.cacheexpire"),    // 响应缓存的有效期（秒），0为永不过期
 		SuccessInherit:  setting.GetBool("run.success"),         // 继承历史成功记录
 		FailureInherit:  setting.GetBool("run.failure"),         // 继承历史失败记录
+		FrontierInherit: setting.GetBool("run.frontier"),        // 持久化请求队列，断点续爬
 		AutoOpenBrowser: setting.GetBool("run.autoopenbrowser"), // 自动打开浏览器
 	}
 }
@@ -25,6 +25,8 @@ const (
 	spiderdir                    = WORK_ROOT + "/spiders"      // 动态规则目录
 	fileoutdir                   = WORK_ROOT + "/file_out"     // 文件（图片、HTML等）结果的输出目录
 	textoutdir                   = WORK_ROOT + "/text_out"     // excel或csv输出方式下，文本结果的输出目录
+	warcoutdir                   = WORK_ROOT + "/warc_out"     // WARC存档文件的输出目录
+	warcmaxsize           int64  = 1 << 30                     // 单个WARC文件的最大字节数，超过时新建文件
 	dbname                       = TAG                         // 数据库名称
 	mgoconnstring         string = "127.0.0.1:27017"           // mongodb连接字符串
 	mgoconncap            int    = 1024                        // mongodb连接池容量
@@ -33,19 +35,51 @@ const (
 	mysqlconncap          int    = 2048                        // mysql连接池容量
 	mysqlmaxallowedpacket int    = 1048576                     // mysql通信缓冲区的最大长度，单位B，默认1MB
 	kafkabrokers          string = "127.0.0.1:9092"            // kafka broker字符串,逗号分割
+	proxystrategy         string = "round-robin"               // 代理IP的选择策略：round-robin、least-used、weighted
+	proxysticky           bool   = false                       // 同一主机的请求是否固定使用同一代理IP
+	proxyprobeurl         string = ""                          // 代理IP健康检查的探测地址，为空时不检查
+	proxyprobesecond      int64  = 60                          // 代理IP健康检查的间隔秒数
+	proxybanafter         int    = 3                           // 代理IP连续失败该次数后暂时停用
+	proxybansecond        int64  = 300                         // 代理IP暂时停用的秒数
+	proxyprovider         string = "file"                      // 代理IP的来源：file、static、api
+	proxylist             string = ""                          // static来源的代理IP列表,逗号分割
+	proxyapi              string = ""                          // api来源的代理IP获取地址，返回文本或JSON
+	dnsservers            string = ""                          // 上游DNS服务器,逗号分割，如8.8.8.8:53，为空时使用系统解析器
+	dnsttl                int64  = 300                         // DNS缓存时长的上限（秒），系统解析器无法获得TTL时即为缓存时长，0为不缓存
+	dnsnegativettl        int64  = 30                          // 域名不存在时的缓存时长（秒），0为不缓存
+	httpmaxidleconns      int    = 512                         // 下载连接池所有主机的最大空闲连接数，0为不限
+	httpmaxidleperhost    int    = 16                          // 下载连接池每个主机的最大空闲连接数
+	httpmaxconnsperhost   int    = 0                           // 下载连接池每个主机的最大连接数（含正在使用的），0为不限
+	httpidlesecond        int64  = 90                          // 下载连接池空闲连接的保持秒数，0为不限
+	httpdisablekeepalives bool   = false                       // 是否禁用长连接，每个请求使用新连接
+	httpdisablehttp2      bool   = false                       // 是否禁用HTTP/2
 
-	mode                   = status.UNSET // 节点角色
-	autoOpenBrowser bool   = false        // 是否自动打开浏览器
-	port            int    = 2015         // 主节点端口
-	master          string = "127.0.0.1"  // 服务器(主节点)地址，不含端口
-	thread          int    = 20           // 全局最大并发量
-	pause           int64  = 300          // 暂停时长参考/ms(随机: Pausetime/2 ~ Pausetime*2)
-	outtype         string = "csv"        // 输出方式
-	dockercap       int    = 10000        // 分段转储容器容量
-	limit           int64  = 0            // 采集上限，0为不限，若在规则中设置初始值为LIMIT则为自定义限制，否则默认限制请求数
-	proxysecond     int64  = 0            // 代理IP更换的间隔秒钟数
-	success         bool   = true         // 继承历史成功记录
-	failure         bool   = true         // 继承历史失败记录
+	mode                    = status.UNSET // 节点角色
+	autoOpenBrowser bool    = false        // 是否自动打开浏览器
+	port            int     = 2015         // 主节点端口
+	master          string  = "127.0.0.1"  // 服务器(主节点)地址，不含端口
+	thread          int     = 20           // 全局最大并发量
+	pause           int64   = 300          // 暂停时长参考/ms(随机: Pausetime/2 ~ Pausetime*2)
+	outtype         string  = "csv"        // 输出方式
+	dockercap       int     = 10000        // 分段转储容器容量
+	limit           int64   = 0            // 采集上限，0为不限，若在规则中设置初始值为LIMIT则为自定义限制，否则默认限制请求数
+	proxysecond     int64   = 0            // 代理IP更换的间隔秒钟数
+	hostrate        float64 = 0            // 每个主机每秒最大请求数，0为不限
+	hostburst       int     = 1            // 每个主机允许的突发请求数
+	hostconns       int     = 0            // 每个主机最大并发连接数，0为不限
+	hostbyip        bool    = false        // 按解析后的IP而非主机名限速
+	bloomafter      int     = 0            // 成功记录超过该数量时改用布隆过滤器去重，0为始终精确去重
+	bloomrate       float64 = 0.001        // 布隆过滤器的误判率
+	autothrottle    bool    = false        // 根据响应延迟及错误率自动调整并发量与请求间隔
+	autominthread   int     = 1            // 自适应并发的最小并发量
+	automaxthread   int     = 0            // 自适应并发的最大并发量，0为全局并发量
+	automindelay    int64   = 0            // 自适应并发的最小请求间隔（毫秒）
+	automaxdelay    int64   = 60000        // 自适应并发的最大请求间隔（毫秒），0为不限
+	cachemode       string  = "off"        // 响应缓存模式：off、record、replay-only、prefer-cache
+	cacheexpire     int64   = 0            // 响应缓存的有效期（秒），0为永不过期
+	success         bool    = true         // 继承历史成功记录
+	failure         bool    = true         // 继承历史失败记录
+	frontier        bool    = false        // 持久化请求队列，断点续爬
 )
 
 var (
@@ -63,7 +97,9 @@ func initViper() *viper.Viper {
 
 		// 先确保目录存在
 		_ = os.MkdirAll(filepath.Clean(HISTORY_DIR), 0777)
+		_ = os.MkdirAll(filepath.Clean(FRONTIER_DIR), 0777)
 		_ = os.MkdirAll(filepath.Clean(CACHE_DIR), 0777)
+		_ = os.MkdirAll(filepath.Clean(COOKIE_DIR), 0777)
 		_ = os.MkdirAll(filepath.Clean(PHANTOMJS_TEMP), 0777)
 
 		// 尝试读取配置文件
@@ -85,6 +121,7 @@ func initViper() *viper.Viper {
 		_ = os.MkdirAll(filepath.Clean(v.GetString("spiderdir")), 0777)
 		_ = os.MkdirAll(filepath.Clean(v.GetString("fileoutdir")), 0777)
 		_ = os.MkdirAll(filepath.Clean(v.GetString("textoutdir")), 0777)
+		_ = os.MkdirAll(filepath.Clean(v.GetString("warcoutdir")), 0777)
 		_viper = v
 	})
 	return _viper
@@ -103,6 +140,8 @@ func defaultConfig(v *viper.Viper) {
 	v.SetDefault("spiderdir", spiderdir)
 	v.SetDefault("fileoutdir", fileoutdir)
 	v.SetDefault("textoutdir", textoutdir)
+	v.SetDefault("warcoutdir", warcoutdir)
+	v.SetDefault("warcmaxsize", warcmaxsize)
 	v.SetDefault("dbname", dbname)
 	v.SetDefault("mgo.username", "")
 	v.SetDefault("mgo.password", "")
@@ -113,6 +152,24 @@ func defaultConfig(v *viper.Viper) {
 	v.SetDefault("mysql.conncap", mysqlconncap)
 	v.SetDefault("mysql.maxallowedpacket", mysqlmaxallowedpacket)
 	v.SetDefault("kafka.brokers", kafkabrokers)
+	v.SetDefault("proxy.strategy", proxystrategy)
+	v.SetDefault("proxy.sticky", proxysticky)
+	v.SetDefault("proxy.probeurl", proxyprobeurl)
+	v.SetDefault("proxy.probesecond", proxyprobesecond)
+	v.SetDefault("proxy.banafter", proxybanafter)
+	v.SetDefault("proxy.bansecond", proxybansecond)
+	v.SetDefault("proxy.provider", proxyprovider)
+	v.SetDefault("proxy.list", proxylist)
+	v.SetDefault("proxy.api", proxyapi)
+	v.SetDefault("dns.servers", dnsservers)
+	v.SetDefault("dns.ttl", dnsttl)
+	v.SetDefault("dns.negativettl", dnsnegativettl)
+	v.SetDefault("http.maxidleconns", httpmaxidleconns)
+	v.SetDefault("http.maxidleperhost", httpmaxidleperhost)
+	v.SetDefault("http.maxconnsperhost", httpmaxconnsperhost)
+	v.SetDefault("http.idlesecond", httpidlesecond)
+	v.SetDefault("http.disablekeepalives", httpdisablekeepalives)
+	v.SetDefault("http.disablehttp2", httpdisablehttp2)
 	v.SetDefault("run.mode", mode)
 	v.SetDefault("run.port", port)
 	v.SetDefault("run.master", master)
@@ -122,8 +179,22 @@ func defaultConfig(v *viper.Viper) {
 	v.SetDefault("run.dockercap", dockercap)
 	v.SetDefault("run.limit", limit)
 	v.SetDefault("run.proxysecond", proxysecond)
+	v.SetDefault("run.hostrate", hostrate)
+	v.SetDefault("run.hostburst", hostburst)
+	v.SetDefault("run.hostconns", hostconns)
+	v.SetDefault("run.hostbyip", hostbyip)
+	v.SetDefault("run.bloomafter", bloomafter)
+	v.SetDefault("run.bloomrate", bloomrate)
+	v.SetDefault("run.autothrottle", autothrottle)
+	v.SetDefault("run.autominthread", autominthread)
+	v.SetDefault("run.automaxthread", automaxthread)
+	v.SetDefault("run.automindelay", automindelay)
+	v.SetDefault("run.automaxdelay", automaxdelay)
+	v.SetDefault("run.cachemode", cachemode)
+	v.SetDefault("run.cacheexpire", cacheexpire)
 	v.SetDefault("run.success", success)
 	v.SetDefault("run.failure", failure)
+	v.SetDefault("run.frontier", frontier)
 	v.SetDefault("run.autoopenbrowser", autoOpenBrowser)
 }
 
@@ -182,6 +253,12 @@ func trySet(v *viper.Viper) {
 	if v.GetString("textoutdir") == "" {
 		v.Set("textoutdir", textoutdir)
 	}
+	if v.GetString("warcoutdir") == "" {
+		v.Set("warcoutdir", warcoutdir)
+	}
+	if v.GetInt64("warcmaxsize") <= 0 {
+		v.Set("warcmaxsize", warcmaxsize)
+	}
 	if v.GetString("dbname") == "" {
 		v.Set("dbname", dbname)
 	}
@@ -213,6 +290,52 @@ func trySet(v *viper.Viper) {
 		v.Set("kafka.brokers", kafkabrokers)
 	}
 
+	// proxy
+	switch v.GetString("proxy.strategy") {
+	case "round-robin", "least-used", "weighted":
+	default:
+		v.Set("proxy.strategy", proxystrategy)
+	}
+	if !v.IsSet("proxy.sticky") {
+		v.Set("proxy.sticky", proxysticky)
+	}
+	if v.GetInt64("proxy.probesecond") <= 0 {
+		v.Set("proxy.probesecond", proxyprobesecond)
+	}
+	if v.GetInt("proxy.banafter") <= 0 {
+		v.Set("proxy.banafter", proxybanafter)
+	}
+	if v.GetInt64("proxy.bansecond") <= 0 {
+		v.Set("proxy.bansecond", proxybansecond)
+	}
+	switch v.GetString("proxy.provider") {
+	case "file", "static", "api":
+	default:
+		v.Set("proxy.provider", proxyprovider)
+	}
+
+	// dns
+	if v.GetInt64("dns.ttl") < 0 {
+		v.Set("dns.ttl", dnsttl)
+	}
+	if v.GetInt64("dns.negativettl") < 0 {
+		v.Set("dns.negativettl", dnsnegativettl)
+	}
+
+	// http
+	if v.GetInt("http.maxidleconns") < 0 {
+		v.Set("http.maxidleconns", httpmaxidleconns)
+	}
+	if v.GetInt("http.maxidleperhost") <= 0 {
+		v.Set("http.maxidleperhost", httpmaxidleperhost)
+	}
+	if v.GetInt("http.maxconnsperhost") < 0 {
+		v.Set("http.maxconnsperhost", httpmaxconnsperhost)
+	}
+	if v.GetInt64("http.idlesecond") < 0 {
+		v.Set("http.idlesecond", httpidlesecond)
+	}
+
 	// run
 	if v.GetInt("run.mode") < status.UNSET || v.GetInt("run.mode") > status.CLIENT {
 		v.Set("run.mode", mode)
@@ -241,12 +364,56 @@ func trySet(v *viper.Viper) {
 	if v.GetInt64("run.proxysecond") <= 0 {
 		v.Set("run.proxysecond", proxysecond)
 	}
+	if v.GetFloat64("run.hostrate") < 0 {
+		v.Set("run.hostrate", hostrate)
+	}
+	if v.GetInt("run.hostburst") <= 0 {
+		v.Set("run.hostburst", hostburst)
+	}
+	if v.GetInt("run.hostconns") < 0 {
+		v.Set("run.hostconns", hostconns)
+	}
+	if !v.IsSet("run.hostbyip") {
+		v.Set("run.hostbyip", hostbyip)
+	}
+	if v.GetInt("run.bloomafter") < 0 {
+		v.Set("run.bloomafter", bloomafter)
+	}
+	if r := v.GetFloat64("run.bloomrate"); r <= 0 || r >= 1 {
+		v.Set("run.bloomrate", bloomrate)
+	}
+	if !v.IsSet("run.autothrottle") {
+		v.Set("run.autothrottle", autothrottle)
+	}
+	if v.GetInt("run.autominthread") <= 0 {
+		v.Set("run.autominthread", autominthread)
+	}
+	if v.GetInt("run.automaxthread") < 0 {
+		v.Set("run.automaxthread", automaxthread)
+	}
+	if v.GetInt64("run.automindelay") < 0 {
+		v.Set("run.automindelay", automindelay)
+	}
+	if v.GetInt64("run.automaxdelay") < 0 {
+		v.Set("run.automaxdelay", automaxdelay)
+	}
+	switch v.GetString("run.cachemode") {
+	case "off", "record", "replay-only", "prefer-cache":
+	default:
+		v.Set("run.cachemode", cachemode)
+	}
+	if v.GetInt64("run.cacheexpire") < 0 {
+		v.Set("run.cacheexpire", cacheexpire)
+	}
 	if !v.IsSet("run.success") {
 		v.Set("run.success", success)
 	}
 	if !v.IsSet("run.failure") {
 		v.Set("run.failure", failure)
 	}
+	if !v.IsSet("run.frontier") {
+		v.Set("run.frontier", frontier)
+	}
 	if !v.IsSet("run.autoopenbrowser") {
 		v.Set("run.autoopenbrowser", autoOpenBrowser)
 	}